@@ -0,0 +1,186 @@
+package dbmodel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+//statement size kept free for the protocol and the text of the statement that is not counted per row
+const batchPacketMargin = 1024
+
+//BatchResult result of one statement of SaveAll
+type BatchResult struct {
+	Table        string //db.table
+	Rows         int    //objects in the statement
+	RowsAffected int64  //-1 when unknown. mysql counts an updated row as 2 and an unchanged row as 0
+	Err          error
+}
+
+//batchGroup objects of one table that have values for the same columns
+type batchGroup struct {
+	dbName  string
+	tblName string
+	fields  []string
+	keys    []string
+	rows    [][]interface{}
+	keyRows map[string]int //index in rows of a primary key
+}
+
+//SaveAll save objects in a transaction on the default store. Objects are grouped by table and the
+//columns that have a value, each group is sent as multi-row insert or update statements that stay
+//within the argument limit of the dialect and the max_allowed_packet of mysql. Returns the result
+//of every statement sent, the transaction is rolled back when one fails. Ids of inserted rows are
+//not set
+func SaveAll(ctx context.Context, objs []DbObject) ([]BatchResult, error) {
+	db, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	var results []BatchResult
+	err = db.WithTx(ctx, func(tx *Tx) error {
+		var err error
+		results, err = saveAll(tx.ctx, tx.store, objs)
+		return err
+	})
+	return results, err
+}
+
+//SaveAll save objects in the transaction, see SaveAll
+func (t *Tx) SaveAll(objs []DbObject) ([]BatchResult, error) {
+	return saveAll(t.ctx, t.store, objs)
+}
+
+//saveAll save objects with multi-row statements, stops at the first statement that fails
+func saveAll(ctx context.Context, db *Store, objs []DbObject) ([]BatchResult, error) {
+	d := db.Dialect()
+	batch, ok := d.(batchUpserter)
+	maxArgs, maxPacket := 0, 0
+	if ok {
+		maxArgs = batch.maxArgs()
+		if query := batch.maxPacketQuery(); query != "" {
+			if err := db.queryRow(ctx, query, nil, &maxPacket); err != nil {
+				return nil, err
+			}
+		}
+	}
+	results := make([]BatchResult, 0)
+	for _, g := range groupObjects(objs) {
+		db.invalidate(g.dbName, g.tblName)
+		table := Table(d, g.dbName, g.tblName)
+		var chunks [][][]interface{}
+		if ok {
+			header := len(batch.upsertRows(table, g.fields, g.keys, 0))
+			chunks = g.chunks(maxArgs, maxPacket-header-batchPacketMargin)
+		} else {
+			//dialect without multi-row statements, one statement per object
+			chunks = g.chunks(len(g.fields), 0)
+		}
+		for _, chunk := range chunks {
+			var query string
+			var args []interface{}
+			if ok {
+				query = batch.upsertRows(table, g.fields, g.keys, len(chunk))
+				for _, row := range chunk {
+					args = append(args, row...)
+				}
+			} else {
+				query, args = d.Upsert(table, g.fields, g.keys, chunk[0])
+			}
+			r := BatchResult{Table: g.dbName + "." + g.tblName, Rows: len(chunk), RowsAffected: -1}
+			res, err := db.exec(ctx, query, args...)
+			if err == nil {
+				if n, e := res.RowsAffected(); e == nil {
+					r.RowsAffected = n
+				}
+			}
+			r.Err = err
+			results = append(results, r)
+			if err != nil {
+				return results, err
+			}
+		}
+	}
+	return results, nil
+}
+
+//groupObjects returns consecutive objects of the same table and columns with a value as groups.
+//Objects are saved in the order of objs, a parent before the child that refers to it. Objects of
+//a group with the same primary key are saved once with the values of the last one, like saving
+//them one by one. Postgres and sqlite fail when a statement updates a row twice
+func groupObjects(objs []DbObject) []*batchGroup {
+	groups := make([]*batchGroup, 0)
+	var g *batchGroup
+	lastKey := ""
+	for _, obj := range objs {
+		dbName, tblName := obj.GetDbInfo()
+		fields, keys, values := saveFields(obj.GetColumns())
+		key := dbName + "." + tblName + "\x00" + strings.Join(fields, "\x00")
+		if g == nil || key != lastKey {
+			g = &batchGroup{dbName: dbName, tblName: tblName, fields: fields, keys: keys, keyRows: make(map[string]int)}
+			lastKey = key
+			groups = append(groups, g)
+		}
+		if len(keys) > 0 {
+			//keys are in the order of fields
+			pk := make([]string, 0, len(keys))
+			for i, f := range fields {
+				if len(pk) < len(keys) && f == keys[len(pk)] {
+					pk = append(pk, fmt.Sprint(values[i]))
+				}
+			}
+			k := strings.Join(pk, "\x00")
+			if i, ok := g.keyRows[k]; ok {
+				g.rows[i] = values
+				continue
+			}
+			g.keyRows[k] = len(g.rows)
+		}
+		g.rows = append(g.rows, values)
+	}
+	return groups
+}
+
+//chunks split rows so a statement has at most maxArgs arguments and its rows take at most
+//maxSize bytes, 0 is no limit. A row that is too large on its own gets its own statement
+func (g *batchGroup) chunks(maxArgs, maxSize int) [][][]interface{} {
+	var chunks [][][]interface{}
+	var chunk [][]interface{}
+	size := 0
+	for _, row := range g.rows {
+		rowSize := rowSize(row)
+		full := maxArgs > 0 && (len(chunk)+1)*len(g.fields) > maxArgs
+		if maxSize > 0 && size+rowSize > maxSize {
+			full = true
+		}
+		if full && len(chunk) > 0 {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			size = 0
+		}
+		chunk = append(chunk, row)
+		size += rowSize
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+//rowSize returns estimated bytes of row in a statement: the values and the placeholders
+func rowSize(row []interface{}) int {
+	size := 4
+	for _, v := range row {
+		size += 4
+		switch t := v.(type) {
+		case nil:
+		case string:
+			size += len(t)
+		case []byte:
+			size += len(t)
+		default:
+			size += len(fmt.Sprint(t))
+		}
+	}
+	return size
+}
@@ -0,0 +1,137 @@
+package dbmodel
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+//saveAllOn SaveAll in a transaction on db
+func saveAllOn(t *testing.T, db *Store, objs []DbObject) ([]BatchResult, error) {
+	t.Helper()
+	var results []BatchResult
+	err := db.WithTx(context.Background(), func(tx *Tx) error {
+		var err error
+		results, err = tx.SaveAll(objs)
+		return err
+	})
+	return results, err
+}
+
+func TestSaveAllChunks(t *testing.T) {
+	db := openSQLite(t, "create table t (id integer primary key, name text not null, n int)")
+	cols := GetColumns(db, "main", "t")
+	obj := func(values map[string]interface{}) DbObject {
+		return testObject{tblName: "t", cols: withValues(cols, values)}
+	}
+	var objs []DbObject
+	for i := 1; i <= 12000; i++ {
+		objs = append(objs, obj(map[string]interface{}{"id": i, "name": "n" + strconv.Itoa(i), "n": i}))
+	}
+	//without id, a group of their own
+	objs = append(objs, obj(map[string]interface{}{"name": "auto"}), obj(map[string]interface{}{"name": "auto"}))
+
+	results, err := saveAllOn(t, db, objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//32766 arguments of sqlite are 10922 rows of 3 columns
+	rows := []int{10922, 1078, 2}
+	if len(results) != len(rows) {
+		t.Fatal("Expected 3 statements, got", results)
+	}
+	for i, r := range results {
+		if r.Table != "main.t" || r.Rows != rows[i] || r.RowsAffected != int64(rows[i]) || r.Err != nil {
+			t.Errorf("Statement %d: got %+v, want %d rows", i, r, rows[i])
+		}
+	}
+	if count := queryInt(t, db, "select count(*) from t"); count != 12002 {
+		t.Error("Expected 12002 rows, got", count)
+	}
+}
+
+func TestSaveAllSameKey(t *testing.T) {
+	db := openSQLite(t, "create table t (id integer primary key, name text not null)")
+	cols := GetColumns(db, "main", "t")
+	objs := []DbObject{
+		testObject{tblName: "t", cols: withValues(cols, map[string]interface{}{"id": 1, "name": "a"})},
+		testObject{tblName: "t", cols: withValues(cols, map[string]interface{}{"id": 2, "name": "b"})},
+		testObject{tblName: "t", cols: withValues(cols, map[string]interface{}{"id": 1, "name": "c"})},
+	}
+	results, err := saveAllOn(t, db, objs)
+	if err != nil || len(results) != 1 || results[0].Rows != 2 {
+		t.Fatal("Expected one statement of 2 rows, got", results, err)
+	}
+	rows, err := QueryTyped(db, "select name from t where id = 1")
+	if err != nil || len(rows) != 1 || rows[0]["name"] != "c" {
+		t.Error("Expected values of the last object, got", rows, err)
+	}
+}
+
+func TestSaveAllOrder(t *testing.T) {
+	db := openSQLite(t, "pragma foreign_keys = on",
+		"create table p (id integer primary key, name text)",
+		"create table c (id integer primary key, pid int not null references p (id))")
+	parent := func(values map[string]interface{}) DbObject {
+		return testObject{tblName: "p", cols: withValues(GetColumns(db, "main", "p"), values)}
+	}
+	child := func(id, pid int) DbObject {
+		return testObject{tblName: "c", cols: withValues(GetColumns(db, "main", "c"), map[string]interface{}{"id": id, "pid": pid})}
+	}
+	//the second parent has other columns, it is saved after the first child and before the second
+	objs := []DbObject{
+		parent(map[string]interface{}{"id": 1}),
+		child(1, 1),
+		parent(map[string]interface{}{"id": 2, "name": "b"}),
+		child(2, 2),
+	}
+	results, err := saveAllOn(t, db, objs)
+	if err != nil || len(results) != 4 {
+		t.Fatal("Expected a statement per object in order, got", results, err)
+	}
+	if count := queryInt(t, db, "select count(*) from c"); count != 2 {
+		t.Error("Expected 2 children, got", count)
+	}
+}
+
+func TestSaveAllRollback(t *testing.T) {
+	db := openSQLite(t, "create table t (id integer primary key, name text not null)", "create table u (id integer primary key, name text not null)")
+	objs := []DbObject{
+		testObject{tblName: "t", cols: withValues(GetColumns(db, "main", "t"), map[string]interface{}{"id": 1, "name": "a"})},
+		testObject{tblName: "u", cols: withValues(GetColumns(db, "main", "u"), map[string]interface{}{"id": 1})},
+	}
+	results, err := saveAllOn(t, db, objs)
+	if err == nil || len(results) != 2 || results[0].Err != nil || results[1].Err == nil {
+		t.Fatal("Expected error of the second statement, got", results, err)
+	}
+	if count := queryInt(t, db, "select count(*) from t"); count != 0 {
+		t.Error("Expected rollback, got", count)
+	}
+}
+
+func TestChunks(t *testing.T) {
+	g := &batchGroup{fields: []string{"id", "name"}}
+	for i := 0; i < 5; i++ {
+		g.rows = append(g.rows, []interface{}{i, "abcdefgh"})
+	}
+	tests := []struct {
+		maxArgs, maxSize int
+		want             []int
+	}{
+		{0, 0, []int{5}},
+		{4, 0, []int{2, 2, 1}},
+		{0, 2 * rowSize(g.rows[0]), []int{2, 2, 1}},
+		{0, 1, []int{1, 1, 1, 1, 1}},
+	}
+	for _, tt := range tests {
+		chunks := g.chunks(tt.maxArgs, tt.maxSize)
+		sizes := make([]int, len(chunks))
+		for i, c := range chunks {
+			sizes[i] = len(c)
+		}
+		if !reflect.DeepEqual(sizes, tt.want) {
+			t.Errorf("chunks(%d, %d) = %v, want %v", tt.maxArgs, tt.maxSize, sizes, tt.want)
+		}
+	}
+}
@@ -0,0 +1,713 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//maxLimit used for offset without limit, mysql and sqlite need a limit before offset
+const maxLimit = "9223372036854775807"
+
+//sqlWriter builds statement text and arguments, placeholders are numbered for the dialect
+type sqlWriter struct {
+	d    Dialect
+	sql  strings.Builder
+	args []interface{}
+}
+
+func (w *sqlWriter) write(str ...string) {
+	for _, s := range str {
+		w.sql.WriteString(s)
+	}
+}
+
+//value write placeholder for v, a *SelectBuilder is written as subquery
+func (w *sqlWriter) value(v interface{}) {
+	if sub, ok := v.(*SelectBuilder); ok {
+		w.write("(")
+		sub.writeSQL(w)
+		w.write(")")
+		return
+	}
+	w.args = append(w.args, v)
+	w.write(w.d.Placeholder(len(w.args)))
+}
+
+//ident write quoted column or table name, db.table is quoted per part. * and expressions
+//with parentheses or spaces (count(*), sum(qty) as total) are written as is
+func (w *sqlWriter) ident(name string) {
+	if name == "*" || strings.ContainsAny(name, "( ") {
+		w.write(name)
+		return
+	}
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		if i > 0 {
+			w.write(".")
+		}
+		if p == "*" {
+			w.write(p)
+		} else {
+			w.write(w.d.Quote(p))
+		}
+	}
+}
+
+//table write quoted table name, with database when dbName is not empty
+func (w *sqlWriter) table(dbName, tblName string) {
+	if dbName == "" {
+		w.write(w.d.Quote(tblName))
+		return
+	}
+	w.write(Table(w.d, dbName, tblName))
+}
+
+//where write where clause, conditions are joined with and
+func (w *sqlWriter) where(conds []Cond) {
+	if len(conds) == 0 {
+		return
+	}
+	w.write(" where ")
+	for i, c := range conds {
+		if i > 0 {
+			w.write(" and ")
+		}
+		c.writeSQL(w)
+	}
+}
+
+//Cond condition for the where clause of a query builder
+type Cond interface {
+	writeSQL(w *sqlWriter)
+}
+
+//Eq column = value, nil is is null, a slice is in (...), a *SelectBuilder is a subquery
+type Eq map[string]interface{}
+
+//NotEq column <> value, nil is is not null, a slice is not in (...)
+type NotEq map[string]interface{}
+
+//Gt column > value
+type Gt map[string]interface{}
+
+//Gte column >= value
+type Gte map[string]interface{}
+
+//Lt column < value
+type Lt map[string]interface{}
+
+//Lte column <= value
+type Lte map[string]interface{}
+
+//Like column like pattern
+type Like map[string]interface{}
+
+//NotLike column not like pattern
+type NotLike map[string]interface{}
+
+//In column in (values...), value is a slice or a *SelectBuilder
+type In map[string]interface{}
+
+//NotIn column not in (values...), value is a slice or a *SelectBuilder
+type NotIn map[string]interface{}
+
+//Between column between from and to
+type Between map[string][2]interface{}
+
+//IsNull columns are null
+type IsNull []string
+
+//NotNull columns are not null
+type NotNull []string
+
+//And all conditions are true
+type And []Cond
+
+//Or one of the conditions is true
+type Or []Cond
+
+//Not condition is false
+type Not struct {
+	Cond Cond
+}
+
+//Expr sql expression, each ? in SQL is replaced by a placeholder for the next value in Args.
+//Without Args SQL is written as is
+type Expr struct {
+	SQL  string
+	Args []interface{}
+}
+
+//exists exists (subquery)
+type exists struct {
+	sub *SelectBuilder
+}
+
+//Exists subquery returns rows
+func Exists(sub *SelectBuilder) Cond {
+	return exists{sub: sub}
+}
+
+//sortedKeys returns keys of map m in sorted order, so statements are the same for the same conditions
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//isList true when v is a slice or array of values, []byte is a value
+func isList(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	if _, ok := v.([]byte); ok {
+		return false
+	}
+	k := reflect.TypeOf(v).Kind()
+	return k == reflect.Slice || k == reflect.Array
+}
+
+//writeList write (placeholders) for list or subquery
+func writeList(w *sqlWriter, v interface{}) {
+	if sub, ok := v.(*SelectBuilder); ok {
+		w.value(sub)
+		return
+	}
+	list := reflect.ValueOf(v)
+	w.write("(")
+	for i := 0; i < list.Len(); i++ {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.value(list.Index(i).Interface())
+	}
+	w.write(")")
+}
+
+//writeMap write condition for each key of m joined with and, in parentheses when there are more
+func writeMap(w *sqlWriter, m map[string]interface{}, fn func(col string, v interface{})) {
+	keys := sortedKeys(m)
+	if len(keys) > 1 {
+		w.write("(")
+	}
+	for i, k := range keys {
+		if i > 0 {
+			w.write(" and ")
+		}
+		fn(k, m[k])
+	}
+	if len(keys) > 1 {
+		w.write(")")
+	}
+}
+
+//writeCompare write column op value for each key of m
+func writeCompare(w *sqlWriter, m map[string]interface{}, op string) {
+	writeMap(w, m, func(col string, v interface{}) {
+		w.ident(col)
+		w.write(" ", op, " ")
+		w.value(v)
+	})
+}
+
+//writeIn write column in (...) or column not in (...) for each key of m, an empty list is never true
+func writeIn(w *sqlWriter, m map[string]interface{}, not bool) {
+	writeMap(w, m, func(col string, v interface{}) {
+		if _, ok := v.(*SelectBuilder); !ok {
+			if !isList(v) {
+				v = []interface{}{v}
+			}
+			if reflect.ValueOf(v).Len() == 0 {
+				if not {
+					w.write("1 = 1")
+				} else {
+					w.write("1 = 0")
+				}
+				return
+			}
+		}
+		w.ident(col)
+		if not {
+			w.write(" not in ")
+		} else {
+			w.write(" in ")
+		}
+		writeList(w, v)
+	})
+}
+
+func (c Eq) writeSQL(w *sqlWriter) {
+	writeMap(w, c, func(col string, v interface{}) {
+		switch {
+		case v == nil:
+			w.ident(col)
+			w.write(" is null")
+		case isList(v):
+			writeIn(w, map[string]interface{}{col: v}, false)
+		default:
+			writeCompare(w, map[string]interface{}{col: v}, "=")
+		}
+	})
+}
+
+func (c NotEq) writeSQL(w *sqlWriter) {
+	writeMap(w, c, func(col string, v interface{}) {
+		switch {
+		case v == nil:
+			w.ident(col)
+			w.write(" is not null")
+		case isList(v):
+			writeIn(w, map[string]interface{}{col: v}, true)
+		default:
+			writeCompare(w, map[string]interface{}{col: v}, "<>")
+		}
+	})
+}
+
+func (c Gt) writeSQL(w *sqlWriter) {
+	writeCompare(w, c, ">")
+}
+
+func (c Gte) writeSQL(w *sqlWriter) {
+	writeCompare(w, c, ">=")
+}
+
+func (c Lt) writeSQL(w *sqlWriter) {
+	writeCompare(w, c, "<")
+}
+
+func (c Lte) writeSQL(w *sqlWriter) {
+	writeCompare(w, c, "<=")
+}
+
+func (c Like) writeSQL(w *sqlWriter) {
+	writeCompare(w, c, "like")
+}
+
+func (c NotLike) writeSQL(w *sqlWriter) {
+	writeCompare(w, c, "not like")
+}
+
+func (c In) writeSQL(w *sqlWriter) {
+	writeIn(w, c, false)
+}
+
+func (c NotIn) writeSQL(w *sqlWriter) {
+	writeIn(w, c, true)
+}
+
+func (c Between) writeSQL(w *sqlWriter) {
+	m := make(map[string]interface{})
+	for k, v := range c {
+		m[k] = v
+	}
+	writeMap(w, m, func(col string, v interface{}) {
+		w.ident(col)
+		w.write(" between ")
+		w.value(c[col][0])
+		w.write(" and ")
+		w.value(c[col][1])
+	})
+}
+
+func (c IsNull) writeSQL(w *sqlWriter) {
+	writeNull(w, c, " is null")
+}
+
+func (c NotNull) writeSQL(w *sqlWriter) {
+	writeNull(w, c, " is not null")
+}
+
+//writeNull write column is null or column is not null for each column
+func writeNull(w *sqlWriter, cols []string, op string) {
+	m := make(map[string]interface{})
+	for _, c := range cols {
+		m[c] = nil
+	}
+	writeMap(w, m, func(col string, v interface{}) {
+		w.ident(col)
+		w.write(op)
+	})
+}
+
+func (c And) writeSQL(w *sqlWriter) {
+	writeJoined(w, c, " and ")
+}
+
+func (c Or) writeSQL(w *sqlWriter) {
+	writeJoined(w, c, " or ")
+}
+
+//writeJoined write conditions joined with op, in parentheses when there are more
+func writeJoined(w *sqlWriter, conds []Cond, op string) {
+	if len(conds) == 0 {
+		//empty and is true, empty or is false
+		if op == " and " {
+			w.write("1 = 1")
+		} else {
+			w.write("1 = 0")
+		}
+		return
+	}
+	if len(conds) > 1 {
+		w.write("(")
+	}
+	for i, c := range conds {
+		if i > 0 {
+			w.write(op)
+		}
+		c.writeSQL(w)
+	}
+	if len(conds) > 1 {
+		w.write(")")
+	}
+}
+
+func (c Not) writeSQL(w *sqlWriter) {
+	w.write("not (")
+	c.Cond.writeSQL(w)
+	w.write(")")
+}
+
+func (c Expr) writeSQL(w *sqlWriter) {
+	if len(c.Args) == 0 {
+		w.write(c.SQL)
+		return
+	}
+	parts := strings.Split(c.SQL, "?")
+	for i, p := range parts {
+		w.write(p)
+		if i < len(parts)-1 {
+			if i < len(c.Args) {
+				w.value(c.Args[i])
+			} else {
+				w.write("?")
+			}
+		}
+	}
+}
+
+func (c exists) writeSQL(w *sqlWriter) {
+	w.write("exists ")
+	w.value(c.sub)
+}
+
+//SelectBuilder builds select statement, create with Select
+type SelectBuilder struct {
+	cols    []string
+	dbName  string
+	tblName string
+	where   []Cond
+	groupBy []string
+	orderBy []string
+	limit   int
+	offset  int
+}
+
+//Select start select statement for columns, all columns when empty
+func Select(cols ...string) *SelectBuilder {
+	return &SelectBuilder{cols: cols}
+}
+
+//From set table, dbName is the database (schema) and may be empty
+func (b *SelectBuilder) From(dbName, tblName string) *SelectBuilder {
+	b.dbName = dbName
+	b.tblName = tblName
+	return b
+}
+
+//Where add conditions, all conditions must be true
+func (b *SelectBuilder) Where(conds ...Cond) *SelectBuilder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+//GroupBy add group by columns
+func (b *SelectBuilder) GroupBy(cols ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, cols...)
+	return b
+}
+
+//OrderBy add order by columns, prefix column with - for descending order
+func (b *SelectBuilder) OrderBy(cols ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, cols...)
+	return b
+}
+
+//Limit set max number of rows, 0 is no limit
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+//Offset set number of rows to skip
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	b.offset = n
+	return b
+}
+
+//ToSQL returns statement and arguments for dialect d
+func (b *SelectBuilder) ToSQL(d Dialect) (string, []interface{}) {
+	w := &sqlWriter{d: d}
+	b.writeSQL(w)
+	return w.sql.String(), w.args
+}
+
+func (b *SelectBuilder) writeSQL(w *sqlWriter) {
+	w.write("select ")
+	if len(b.cols) == 0 {
+		w.write("*")
+	}
+	for i, c := range b.cols {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.ident(c)
+	}
+	w.write(" from ")
+	w.table(b.dbName, b.tblName)
+	w.where(b.where)
+	for i, c := range b.groupBy {
+		if i == 0 {
+			w.write(" group by ")
+		} else {
+			w.write(", ")
+		}
+		w.ident(c)
+	}
+	for i, c := range b.orderBy {
+		if i == 0 {
+			w.write(" order by ")
+		} else {
+			w.write(", ")
+		}
+		if strings.HasPrefix(c, "-") {
+			w.ident(c[1:])
+			w.write(" desc")
+		} else {
+			w.ident(strings.TrimPrefix(c, "+"))
+		}
+	}
+	if b.limit > 0 {
+		w.write(" limit ", strconv.Itoa(b.limit))
+	} else if b.offset > 0 {
+		w.write(" limit ", maxLimit)
+	}
+	if b.offset > 0 {
+		w.write(" offset ", strconv.Itoa(b.offset))
+	}
+}
+
+//Query run select on db, values are strings like Query
+func (b *SelectBuilder) Query(db *Store) ([]map[string]interface{}, error) {
+	return b.QueryContext(context.Background(), db)
+}
+
+//QueryContext Query with context
+func (b *SelectBuilder) QueryContext(ctx context.Context, db *Store) ([]map[string]interface{}, error) {
+	query, args := b.ToSQL(db.Dialect())
+	return queryMaps(ctx, db, query, args...)
+}
+
+//QueryTyped run select on db, values are typed like QueryTyped
+func (b *SelectBuilder) QueryTyped(db *Store) ([]map[string]interface{}, error) {
+	return b.QueryTypedContext(context.Background(), db)
+}
+
+//QueryTypedContext QueryTyped with context
+func (b *SelectBuilder) QueryTypedContext(ctx context.Context, db *Store) ([]map[string]interface{}, error) {
+	query, args := b.ToSQL(db.Dialect())
+	return queryTyped(ctx, db, query, args...)
+}
+
+//QueryRows run select on db, see QueryRows
+func (b *SelectBuilder) QueryRows(db *Store) (*Rows, error) {
+	return b.QueryRowsContext(context.Background(), db)
+}
+
+//QueryRowsContext QueryRows with context
+func (b *SelectBuilder) QueryRowsContext(ctx context.Context, db *Store) (*Rows, error) {
+	query, args := b.ToSQL(db.Dialect())
+	return QueryRowsContext(ctx, db, query, args...)
+}
+
+//InsertBuilder builds insert statement, create with Insert
+type InsertBuilder struct {
+	dbName  string
+	tblName string
+	rows    []map[string]interface{}
+}
+
+//Insert start insert statement into table, dbName may be empty
+func Insert(dbName, tblName string) *InsertBuilder {
+	return &InsertBuilder{dbName: dbName, tblName: tblName}
+}
+
+//Values add row, the columns of the first row are used for all rows
+func (b *InsertBuilder) Values(row map[string]interface{}) *InsertBuilder {
+	b.rows = append(b.rows, row)
+	return b
+}
+
+//ToSQL returns statement and arguments for dialect d
+func (b *InsertBuilder) ToSQL(d Dialect) (string, []interface{}) {
+	w := &sqlWriter{d: d}
+	w.write("insert into ")
+	w.table(b.dbName, b.tblName)
+	if len(b.rows) == 0 {
+		return w.sql.String(), w.args
+	}
+	cols := sortedKeys(b.rows[0])
+	w.write(" (")
+	for i, c := range cols {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.ident(c)
+	}
+	w.write(") values ")
+	for i, row := range b.rows {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.write("(")
+		for j, c := range cols {
+			if j > 0 {
+				w.write(", ")
+			}
+			w.value(row[c])
+		}
+		w.write(")")
+	}
+	return w.sql.String(), w.args
+}
+
+//Exec run insert on the primary
+func (b *InsertBuilder) Exec(db *Store) (sql.Result, error) {
+	return b.ExecContext(context.Background(), db)
+}
+
+//ExecContext Exec with context
+func (b *InsertBuilder) ExecContext(ctx context.Context, db *Store) (sql.Result, error) {
+	defer db.invalidate(b.dbName, b.tblName)
+	if len(b.rows) == 0 {
+		return nil, errors.New("No values to insert into " + b.tblName)
+	}
+	query, args := b.ToSQL(db.Dialect())
+	return db.exec(ctx, query, args...)
+}
+
+//UpdateBuilder builds update statement, create with Update
+type UpdateBuilder struct {
+	dbName  string
+	tblName string
+	set     map[string]interface{}
+	where   []Cond
+}
+
+//Update start update statement for table, dbName may be empty
+func Update(dbName, tblName string) *UpdateBuilder {
+	return &UpdateBuilder{dbName: dbName, tblName: tblName, set: make(map[string]interface{})}
+}
+
+//Set set columns to values, a *SelectBuilder value is a subquery
+func (b *UpdateBuilder) Set(values map[string]interface{}) *UpdateBuilder {
+	for k, v := range values {
+		b.set[k] = v
+	}
+	return b
+}
+
+//Where add conditions, all conditions must be true
+func (b *UpdateBuilder) Where(conds ...Cond) *UpdateBuilder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+//ToSQL returns statement and arguments for dialect d
+func (b *UpdateBuilder) ToSQL(d Dialect) (string, []interface{}) {
+	w := &sqlWriter{d: d}
+	w.write("update ")
+	w.table(b.dbName, b.tblName)
+	w.write(" set ")
+	for i, c := range sortedKeys(b.set) {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.ident(c)
+		w.write(" = ")
+		w.value(b.set[c])
+	}
+	w.where(b.where)
+	return w.sql.String(), w.args
+}
+
+//Exec run update on the primary. Returns error without where conditions, use Expr{SQL: "1 = 1"}
+//to update all rows
+func (b *UpdateBuilder) Exec(db *Store) (sql.Result, error) {
+	return b.ExecContext(context.Background(), db)
+}
+
+//ExecContext Exec with context
+func (b *UpdateBuilder) ExecContext(ctx context.Context, db *Store) (sql.Result, error) {
+	defer db.invalidate(b.dbName, b.tblName)
+	if len(b.set) == 0 {
+		return nil, errors.New("No values to update in " + b.tblName)
+	}
+	if len(b.where) == 0 {
+		return nil, errors.New("No where conditions for update of " + b.tblName)
+	}
+	query, args := b.ToSQL(db.Dialect())
+	return db.exec(ctx, query, args...)
+}
+
+//DeleteBuilder builds delete statement, create with DeleteFrom
+type DeleteBuilder struct {
+	dbName  string
+	tblName string
+	where   []Cond
+}
+
+//DeleteFrom start delete statement for table, dbName may be empty
+func DeleteFrom(dbName, tblName string) *DeleteBuilder {
+	return &DeleteBuilder{dbName: dbName, tblName: tblName}
+}
+
+//Where add conditions, all conditions must be true
+func (b *DeleteBuilder) Where(conds ...Cond) *DeleteBuilder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+//ToSQL returns statement and arguments for dialect d
+func (b *DeleteBuilder) ToSQL(d Dialect) (string, []interface{}) {
+	w := &sqlWriter{d: d}
+	w.write("delete from ")
+	w.table(b.dbName, b.tblName)
+	w.where(b.where)
+	return w.sql.String(), w.args
+}
+
+//Exec run delete on the primary. Returns error without where conditions, use Expr{SQL: "1 = 1"}
+//to delete all rows
+func (b *DeleteBuilder) Exec(db *Store) (sql.Result, error) {
+	return b.ExecContext(context.Background(), db)
+}
+
+//ExecContext Exec with context
+func (b *DeleteBuilder) ExecContext(ctx context.Context, db *Store) (sql.Result, error) {
+	defer db.invalidate(b.dbName, b.tblName)
+	if len(b.where) == 0 {
+		return nil, errors.New("No where conditions for delete from " + b.tblName)
+	}
+	query, args := b.ToSQL(db.Dialect())
+	return db.exec(ctx, query, args...)
+}
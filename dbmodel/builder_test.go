@@ -0,0 +1,71 @@
+package dbmodel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuilderToSQL(t *testing.T) {
+	sel := func() *SelectBuilder {
+		return Select("id", "count(*) as n").From("shop", "orders").
+			Where(Eq{"status": "open"}, Or{Gt{"qty": 10}, In{"id": []int{1, 2}}}, IsNull{"deleted"}).
+			GroupBy("id").OrderBy("-created", "id").Limit(10).Offset(20)
+	}
+	tests := []struct {
+		name    string
+		dialect Dialect
+		build   func(d Dialect) (string, []interface{})
+		sql     string
+		args    []interface{}
+	}{
+		{"mysql select", MySQL{}, sel().ToSQL,
+			"select `id`, count(*) as n from `shop`.`orders` where `status` = ? and (`qty` > ? or `id` in (?, ?)) and `deleted` is null group by `id` order by `created` desc, `id` limit 10 offset 20",
+			[]interface{}{"open", 10, 1, 2}},
+		{"postgres select", Postgres{}, sel().ToSQL,
+			`select "id", count(*) as n from "shop"."orders" where "status" = $1 and ("qty" > $2 or "id" in ($3, $4)) and "deleted" is null group by "id" order by "created" desc, "id" limit 10 offset 20`,
+			[]interface{}{"open", 10, 1, 2}},
+		{"sqlite select", SQLite{}, sel().ToSQL,
+			`select "id", count(*) as n from "shop"."orders" where "status" = ? and ("qty" > ? or "id" in (?, ?)) and "deleted" is null group by "id" order by "created" desc, "id" limit 10 offset 20`,
+			[]interface{}{"open", 10, 1, 2}},
+		{"offset without limit", MySQL{}, Select().From("", "t").Offset(5).ToSQL,
+			"select * from `t` limit " + maxLimit + " offset 5", nil},
+		{"mysql insert", MySQL{}, Insert("shop", "orders").Values(map[string]interface{}{"name": "a", "id": 1}).Values(map[string]interface{}{"name": "b", "id": 2}).ToSQL,
+			"insert into `shop`.`orders` (`id`, `name`) values (?, ?), (?, ?)", []interface{}{1, "a", 2, "b"}},
+		{"postgres insert", Postgres{}, Insert("shop", "orders").Values(map[string]interface{}{"name": "a", "id": 1}).ToSQL,
+			`insert into "shop"."orders" ("id", "name") values ($1, $2)`, []interface{}{1, "a"}},
+		{"postgres update", Postgres{}, Update("shop", "orders").Set(map[string]interface{}{"qty": 3}).Where(Eq{"id": 1}).ToSQL,
+			`update "shop"."orders" set "qty" = $1 where "id" = $2`, []interface{}{3, 1}},
+		{"sqlite delete", SQLite{}, DeleteFrom("main", "orders").Where(Lt{"qty": 1}, NotNull{"name"}).ToSQL,
+			`delete from "main"."orders" where "qty" < ? and "name" is not null`, []interface{}{1}},
+		{"subquery", Postgres{}, Select().From("shop", "lines").Where(In{"order_id": Select("id").From("shop", "orders").Where(Eq{"status": "open"})}, Eq{"qty": 2}).ToSQL,
+			`select * from "shop"."lines" where "order_id" in (select "id" from "shop"."orders" where "status" = $1) and "qty" = $2`, []interface{}{"open", 2}},
+	}
+	for _, tt := range tests {
+		query, args := tt.build(tt.dialect)
+		if query != tt.sql {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, query, tt.sql)
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: got args %v, want %v", tt.name, args, tt.args)
+		}
+	}
+}
+
+func TestBuilderQuery(t *testing.T) {
+	db := openSQLite(t, "create table orders (id integer primary key, name text, qty int)")
+	for i, name := range []string{"a", "b", "c"} {
+		if _, err := Insert("main", "orders").Values(map[string]interface{}{"id": i + 1, "name": name, "qty": i}).Exec(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Update("main", "orders").Set(map[string]interface{}{"qty": 9}).Where(Eq{"name": "a"}).Exec(db); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := Select("name").From("main", "orders").Where(Gte{"qty": 2}).OrderBy("-qty").QueryTyped(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0]["name"] != "a" || rows[1]["name"] != "c" {
+		t.Error("Expected a and c, got", rows)
+	}
+}
@@ -0,0 +1,321 @@
+package dbmodel
+
+import (
+	"container/list"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//default query cache limits
+const (
+	defaultCacheEntries   = 1000
+	defaultCacheEntryRows = 1000
+)
+
+//tables read by a select, the query is not cached when tables are listed with commas
+var (
+	cacheTableReg = regexp.MustCompile("(?i)\\b(?:from|join)\\s+((?:[`\"]?[\\w$]+[`\"]?\\s*\\.\\s*)?[`\"]?[\\w$]+[`\"]?)")
+	cacheCommaReg = regexp.MustCompile("(?i)\\bfrom\\s+[`\"\\w$.]+(?:\\s+(?:as\\s+)?\\w+)?\\s*,")
+)
+
+//CacheOptions query cache settings. Results of selects are cached by query and arguments until
+//their time to live expires or a table they read from is written by dbmodel
+type CacheOptions struct {
+	TTL          time.Duration            //default time to live, 0 only caches tables in TableTTL
+	TableTTL     map[string]time.Duration //time to live by "db.table" or "table", 0 disables caching for the table
+	MaxEntries   int                      //max cached results, least recently used are evicted. Default 1000
+	MaxEntryRows int                      //results with more rows are not cached. Default 1000
+}
+
+//CacheStats query cache statistics
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64 //entries dropped for MaxEntries
+	Invalidations uint64 //entries dropped for writes to their tables
+	Entries       int
+}
+
+//tableName table read by a cached query, db is empty when the query does not name it
+type tableName struct {
+	db  string
+	tbl string
+}
+
+//rawResult cached query result as text, NULL is nil
+type rawResult struct {
+	columns []string
+	types   []string
+	rows    [][]sql.RawBytes
+}
+
+type cacheEntry struct {
+	key     string
+	tables  []tableName
+	expires time.Time
+	res     *rawResult
+}
+
+//queryCache LRU cache of query results
+type queryCache struct {
+	mutex   sync.Mutex
+	opts    CacheOptions
+	lru     *list.List //*cacheEntry, most recently used first
+	entries map[string]*list.Element
+	gen     uint64 //incremented on invalidation, results read before are not stored
+	stats   CacheStats
+}
+
+//cacheRecorder copies rows of a result while it is read
+type cacheRecorder struct {
+	res  rawResult
+	max  int
+	gen  uint64
+	full bool
+}
+
+//EnableCache cache results of selects on s, see CacheOptions. Reads from Primary() are not cached
+func (s *Store) EnableCache(opts CacheOptions) {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = defaultCacheEntries
+	}
+	if opts.MaxEntryRows <= 0 {
+		opts.MaxEntryRows = defaultCacheEntryRows
+	}
+	s.cache = &queryCache{opts: opts, lru: list.New(), entries: make(map[string]*list.Element)}
+}
+
+//CacheStats returns statistics of the query cache
+func (s *Store) CacheStats() CacheStats {
+	if s.cache == nil {
+		return CacheStats{}
+	}
+	s.cache.mutex.Lock()
+	defer s.cache.mutex.Unlock()
+	stats := s.cache.stats
+	stats.Entries = s.cache.lru.Len()
+	return stats
+}
+
+//InvalidateCache drop cached results that read from tables ("db.table" or "table"), all results
+//when no tables are given. Use after writes that do not go through dbmodel
+func (s *Store) InvalidateCache(tables ...string) {
+	if s.cache == nil {
+		return
+	}
+	if len(tables) == 0 {
+		s.cache.invalidate(nil)
+	}
+	for _, t := range tables {
+		s.cache.invalidate(parseTableName(t))
+	}
+}
+
+//invalidate drop cached results that read from dbName.tblName, on commit for a transaction
+func (s *Store) invalidate(dbName, tblName string) {
+	if s.tx != nil {
+		s.tx.wrote(dbName, tblName)
+	} else if s.cache != nil {
+		s.cache.invalidate(&tableName{db: dbName, tbl: tblName})
+	}
+}
+
+//cacheLookup returns cache, key and tables for query. key is empty when the query is not cached
+func (s *Store) cacheLookup(query string, args []interface{}) (*queryCache, string, []tableName) {
+	if s.cache == nil || s.primary {
+		return nil, "", nil
+	}
+	tables := queryTables(query)
+	if len(tables) == 0 || s.cache.ttl(tables) <= 0 {
+		return nil, "", nil
+	}
+	return s.cache, cacheKey(query, args), tables
+}
+
+//queryTables returns tables read by select query, nil when they are not known
+func queryTables(query string) []tableName {
+	q := strings.TrimSpace(query)
+	if len(q) < 6 || !strings.EqualFold(q[:6], "select") {
+		return nil
+	}
+	if cacheCommaReg.MatchString(q) {
+		return nil
+	}
+	var tables []tableName
+	for _, m := range cacheTableReg.FindAllStringSubmatch(q, -1) {
+		if t := parseTableName(m[1]); t != nil {
+			tables = append(tables, *t)
+		}
+	}
+	return tables
+}
+
+//parseTableName returns table for db.table or table, quotes are removed
+func parseTableName(name string) *tableName {
+	parts := strings.Split(name, ".")
+	for i := range parts {
+		parts[i] = strings.Trim(parts[i], "`\" ")
+	}
+	switch len(parts) {
+	case 1:
+		return &tableName{tbl: parts[0]}
+	case 2:
+		return &tableName{db: parts[0], tbl: parts[1]}
+	}
+	return nil
+}
+
+//cacheKey returns query with whitespace outside quotes collapsed, followed by the arguments
+func cacheKey(query string, args []interface{}) string {
+	var b strings.Builder
+	var quote rune
+	space := false
+	for _, r := range strings.TrimSpace(query) {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			space = true
+			continue
+		}
+		if space {
+			b.WriteRune(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	for _, a := range args {
+		b.WriteString("\x00")
+		b.WriteString(fmt.Sprintf("%T=%v", a, a))
+	}
+	return b.String()
+}
+
+//ttl returns shortest time to live of tables
+func (c *queryCache) ttl(tables []tableName) time.Duration {
+	var ret time.Duration = -1
+	for _, t := range tables {
+		ttl, ok := c.opts.TableTTL[t.db+"."+t.tbl]
+		if !ok {
+			if ttl, ok = c.opts.TableTTL[t.tbl]; !ok {
+				ttl = c.opts.TTL
+			}
+		}
+		if ret < 0 || ttl < ret {
+			ret = ttl
+		}
+	}
+	return ret
+}
+
+//get returns cached result for key when it has not expired
+func (c *queryCache) get(key string) (*rawResult, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*cacheEntry)
+		if time.Now().Before(e.expires) {
+			c.lru.MoveToFront(el)
+			c.stats.Hits++
+			return e.res, true
+		}
+		c.remove(el)
+	}
+	c.stats.Misses++
+	return nil, false
+}
+
+//generation returns the invalidation counter, take it before running the query
+func (c *queryCache) generation() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.gen
+}
+
+//record returns recorder for the rows of a result that is not cached yet, gen is the generation
+//before the query was run
+func (c *queryCache) record(gen uint64, columns, types []string) *cacheRecorder {
+	return &cacheRecorder{res: rawResult{columns: columns, types: types}, max: c.opts.MaxEntryRows, gen: gen}
+}
+
+//add copy row, the recorder is full when the result has too many rows
+func (r *cacheRecorder) add(values []sql.RawBytes) {
+	if r == nil || r.full {
+		return
+	}
+	if len(r.res.rows) >= r.max {
+		r.full = true
+		r.res.rows = nil
+		return
+	}
+	row := make([]sql.RawBytes, len(values))
+	for i, v := range values {
+		if v != nil {
+			row[i] = append(sql.RawBytes{}, v...)
+		}
+	}
+	r.res.rows = append(r.res.rows, row)
+}
+
+//put store recorded result, unless it is too large or tables were written while it was read
+func (c *queryCache) put(key string, tables []tableName, r *cacheRecorder) {
+	if r.full {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if r.gen != c.gen {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	e := &cacheEntry{key: key, tables: tables, expires: time.Now().Add(c.ttl(tables)), res: &r.res}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.opts.MaxEntries {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+//invalidate drop results that read from table t, all results when t is nil
+func (c *queryCache) invalidate(t *tableName) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.gen++
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if t == nil || el.Value.(*cacheEntry).reads(*t) {
+			c.remove(el)
+			c.stats.Invalidations++
+		}
+		el = next
+	}
+}
+
+//reads true when entry read from table t, a table without database matches any database
+func (e *cacheEntry) reads(t tableName) bool {
+	for _, et := range e.tables {
+		if et.tbl == t.tbl && (et.db == "" || t.db == "" || et.db == t.db) {
+			return true
+		}
+	}
+	return false
+}
+
+//remove entry, caller holds the mutex
+func (c *queryCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	e := el.Value.(*cacheEntry)
+	if c.entries[e.key] == el {
+		delete(c.entries, e.key)
+	}
+}
@@ -0,0 +1,101 @@
+package dbmodel
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCacheHitAndInvalidate(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t,
+		"create table countries (id integer primary key, name text)",
+		"insert into countries values (1, 'nl')",
+	)
+	db.EnableCache(CacheOptions{TableTTL: map[string]time.Duration{"countries": time.Minute}})
+	name := func() interface{} {
+		t.Helper()
+		rows, err := QueryTyped(db, "select name from countries where id = ?", 1)
+		if err != nil || len(rows) != 1 {
+			t.Fatal(rows, err)
+		}
+		return rows[0]["name"]
+	}
+
+	if name() != "nl" || name() != "nl" {
+		t.Fatal("Expected nl")
+	}
+	if stats := db.CacheStats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Error("Expected 1 hit and 1 miss, got", stats)
+	}
+
+	//a write that does not go through dbmodel is not seen until the cache is invalidated
+	if _, err := db.DB().Exec("update countries set name = 'be'"); err != nil {
+		t.Fatal(err)
+	}
+	if name() != "nl" {
+		t.Error("Expected cached result")
+	}
+	db.InvalidateCache("main.countries")
+	if name() != "be" {
+		t.Error("Expected result after InvalidateCache")
+	}
+
+	//saves drop the results of the table
+	cols := GetColumns(db, "main", "countries")
+	if _, _, err := save(ctx, db, "main", "countries", withValues(cols, map[string]interface{}{"id": 1, "name": "de"})); err != nil {
+		t.Fatal(err)
+	}
+	if name() != "de" {
+		t.Error("Expected result after save")
+	}
+	if stats := db.CacheStats(); stats.Invalidations != 2 {
+		t.Error("Expected 2 invalidations, got", stats)
+	}
+}
+
+func TestCacheTTLAndEviction(t *testing.T) {
+	db := openSQLite(t, "create table t (id integer primary key)")
+	db.EnableCache(CacheOptions{TTL: 200 * time.Millisecond, MaxEntries: 2})
+	for _, id := range []int{1, 2, 3} {
+		if _, err := Query(db, "select * from t where id = ?", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := db.CacheStats(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Error("Expected 1 eviction, got", stats)
+	}
+	//whitespace outside quotes does not change the key
+	Query(db, "select  *  from t\nwhere id = ?", 3)
+	if stats := db.CacheStats(); stats.Hits != 1 {
+		t.Error("Expected hit, got", stats)
+	}
+	time.Sleep(250 * time.Millisecond)
+	Query(db, "select * from t where id = ?", 3)
+	if stats := db.CacheStats(); stats.Hits != 1 || stats.Misses != 4 {
+		t.Error("Expected miss after ttl, got", stats)
+	}
+	//reads from the primary skip the cache
+	QueryTyped(db.Primary(), "select * from t where id = ?", 3)
+	if stats := db.CacheStats(); stats.Hits != 1 || stats.Misses != 4 {
+		t.Error("Expected primary read to skip the cache, got", stats)
+	}
+}
+
+func TestQueryTables(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []tableName
+	}{
+		{"select * from `shop`.`orders` o join lines on 1", []tableName{{db: "shop", tbl: "orders"}, {tbl: "lines"}}},
+		{`select * from "main".t left join "u" using (id)`, []tableName{{db: "main", tbl: "t"}, {tbl: "u"}}},
+		{"select * from a, b", nil},
+		{"select 1", nil},
+	}
+	for _, tt := range tests {
+		if got := queryTables(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("queryTables(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package dbmodel
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//read policies for choosing a replica
+const (
+	RoundRobin = "round_robin"
+	LeastConn  = "least_conn"
+)
+
+//replicaDownTime how long a replica is skipped after a connection error
+var replicaDownTime = 30 * time.Second
+
+//replica read-only connection pool
+type replica struct {
+	db        *sql.DB
+	addr      string
+	downUntil int64 //unix nano
+}
+
+//replicaSet replicas of a store and the policy to choose between them
+type replicaSet struct {
+	list   []*replica
+	policy string
+	next   uint32
+}
+
+//healthy returns false while replica is marked down
+func (r *replica) healthy() bool {
+	return atomic.LoadInt64(&r.downUntil) < time.Now().UnixNano()
+}
+
+//markDown skip replica for replicaDownTime
+func (r *replica) markDown() {
+	atomic.StoreInt64(&r.downUntil, time.Now().Add(replicaDownTime).UnixNano())
+}
+
+//markUp use replica again
+func (r *replica) markUp() {
+	atomic.StoreInt64(&r.downUntil, 0)
+}
+
+//pick returns a healthy replica, nil when all replicas are down
+func (rs *replicaSet) pick() *replica {
+	if rs == nil || len(rs.list) == 0 {
+		return nil
+	}
+	if rs.policy == LeastConn {
+		var best *replica
+		for _, r := range rs.list {
+			if r.healthy() && (best == nil || r.db.Stats().InUse < best.db.Stats().InUse) {
+				best = r
+			}
+		}
+		return best
+	}
+	start := atomic.AddUint32(&rs.next, 1)
+	for i := 0; i < len(rs.list); i++ {
+		r := rs.list[(int(start)+i)%len(rs.list)]
+		if r.healthy() {
+			return r
+		}
+	}
+	return nil
+}
+
+//close close all replica pools
+func (rs *replicaSet) close() error {
+	var ret error
+	if rs == nil {
+		return nil
+	}
+	for _, r := range rs.list {
+		if err := r.db.Close(); err != nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+//openReplicas open connection pools for the replicas in cfg
+func openReplicas(cfg Config) (*replicaSet, error) {
+	if len(cfg.Replicas) == 0 {
+		return nil, nil
+	}
+	rs := &replicaSet{policy: cfg.ReadPolicy}
+	for _, rc := range cfg.Replicas {
+		rc = replicaConfig(cfg, rc)
+		//a replica that is down should not stop the store from opening
+		rc.Ping = false
+		db, err := ConnectConfig(rc)
+		if err != nil {
+			rs.close()
+			return nil, errors.New("Replica " + rc.Host + rc.Socket + ": " + err.Error())
+		}
+		_, addr := rc.address()
+		r := &replica{db: db, addr: addr}
+		if cfg.Ping && db.Ping() != nil {
+			r.markDown()
+		}
+		rs.list = append(rs.list, r)
+	}
+	return rs, nil
+}
+
+//replicaConfig returns replica config with empty values taken from the primary
+func replicaConfig(primary, r Config) Config {
+	ret := primary
+	ret.Replicas = nil
+	ret.Host = r.Host
+	ret.Socket = r.Socket
+	if r.Protocol != "" {
+		ret.Protocol = r.Protocol
+	}
+	if r.Port != 0 {
+		ret.Port = r.Port
+	}
+	if r.User != "" {
+		ret.User = r.User
+		ret.Password = r.Password
+	}
+	if r.Database != "" {
+		ret.Database = r.Database
+	}
+	if r.MaxOpenConns != 0 {
+		ret.MaxOpenConns = r.MaxOpenConns
+	}
+	if r.MaxIdleConns != 0 {
+		ret.MaxIdleConns = r.MaxIdleConns
+	}
+	return ret
+}
+
+//parseReplicas parse comma separated list of host:port
+func parseReplicas(s string) ([]Config, error) {
+	var ret []Config
+	for _, addr := range strings.Split(s, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		c := Config{Host: addr}
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			c.Host = host
+			if c.Port, err = strconv.Atoi(port); err != nil {
+				return ret, errors.New("invalid port for replica " + addr)
+			}
+		}
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+//isConnError true when err means the connection is unusable
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr)
+}
@@ -0,0 +1,134 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+//testConnector connects to nothing, connections can only be held. With err set it fails to connect
+type testConnector struct {
+	err error
+}
+
+type testConn struct{}
+
+func (c testConnector) Connect(context.Context) (driver.Conn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return testConn{}, nil
+}
+
+func (c testConnector) Driver() driver.Driver              { return nil }
+func (testConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("Not supported") }
+func (testConn) Close() error                              { return nil }
+func (testConn) Begin() (driver.Tx, error)                 { return nil, errors.New("Not supported") }
+
+//testReplicas returns replica set with a pool for each addr
+func testReplicas(t *testing.T, policy string, addrs ...string) *replicaSet {
+	rs := &replicaSet{policy: policy}
+	for _, addr := range addrs {
+		rs.list = append(rs.list, &replica{db: sql.OpenDB(testConnector{}), addr: addr})
+	}
+	t.Cleanup(func() { rs.close() })
+	return rs
+}
+
+//picks returns addresses of n picks
+func picks(rs *replicaSet, n int) []string {
+	var ret []string
+	for i := 0; i < n; i++ {
+		if r := rs.pick(); r != nil {
+			ret = append(ret, r.addr)
+		} else {
+			ret = append(ret, "")
+		}
+	}
+	return ret
+}
+
+func TestPickRoundRobin(t *testing.T) {
+	rs := testReplicas(t, RoundRobin, "a", "b", "c")
+	if got := picks(rs, 4); !reflect.DeepEqual(got, []string{"b", "c", "a", "b"}) {
+		t.Error("Expected replicas in turn, got", got)
+	}
+	rs.list[2].markDown()
+	if got := picks(rs, 4); !reflect.DeepEqual(got, []string{"a", "a", "b", "a"}) {
+		t.Error("Expected c to be skipped, got", got)
+	}
+	rs.list[0].markDown()
+	rs.list[1].markDown()
+	if got := rs.pick(); got != nil {
+		t.Error("Expected nil when all replicas are down, got", got.addr)
+	}
+	rs.list[2].markUp()
+	if got := picks(rs, 2); !reflect.DeepEqual(got, []string{"c", "c"}) {
+		t.Error("Expected c after markUp, got", got)
+	}
+	if (*replicaSet)(nil).pick() != nil {
+		t.Error("Expected nil without replicas")
+	}
+}
+
+func TestPickLeastConn(t *testing.T) {
+	ctx := context.Background()
+	rs := testReplicas(t, LeastConn, "a", "b")
+	conn, err := rs.list[0].db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := picks(rs, 2); !reflect.DeepEqual(got, []string{"b", "b"}) {
+		t.Error("Expected replica without connections in use, got", got)
+	}
+	conn2, _ := rs.list[1].db.Conn(ctx)
+	conn3, _ := rs.list[1].db.Conn(ctx)
+	if got := rs.pick(); got.addr != "a" {
+		t.Error("Expected a with 1 connection in use, got", got.addr)
+	}
+	conn.Close()
+	conn2.Close()
+	conn3.Close()
+	rs.list[0].markDown()
+	if got := rs.pick(); got.addr != "b" {
+		t.Error("Expected b while a is down, got", got.addr)
+	}
+}
+
+func TestReplicaConfig(t *testing.T) {
+	primary := Config{Host: "db1", Port: 3306, User: "app", Password: "secret", Database: "shop", MaxOpenConns: 10,
+		Replicas: []Config{{Host: "db2"}}}
+	got := replicaConfig(primary, Config{Host: "db2", Port: 3307})
+	if got.Host != "db2" || got.Port != 3307 || got.User != "app" || got.Password != "secret" || got.Database != "shop" || got.MaxOpenConns != 10 || got.Replicas != nil {
+		t.Errorf("Expected values of the primary, got %+v", got)
+	}
+	got = replicaConfig(primary, Config{Socket: "/tmp/r.sock", User: "ro"})
+	if got.Host != "" || got.Socket != "/tmp/r.sock" || got.User != "ro" || got.Password != "" {
+		t.Errorf("Expected socket and user of the replica without the password of the primary, got %+v", got)
+	}
+
+	replicas, err := parseReplicas("db2, db3:3307,,")
+	if err != nil || !reflect.DeepEqual(replicas, []Config{{Host: "db2"}, {Host: "db3", Port: 3307}}) {
+		t.Error("Invalid replicas", replicas, err)
+	}
+	if _, err = parseReplicas("db2:x"); err == nil {
+		t.Error("Expected error for invalid port")
+	}
+}
+
+func TestIsConnError(t *testing.T) {
+	for _, err := range []error{driver.ErrBadConn, &net.OpError{Op: "dial", Err: errors.New("refused")}} {
+		if !isConnError(err) {
+			t.Error("Expected connection error:", err)
+		}
+	}
+	for _, err := range []error{nil, errors.New("syntax error"), sql.ErrNoRows} {
+		if isConnError(err) {
+			t.Error("Expected no connection error:", err)
+		}
+	}
+}
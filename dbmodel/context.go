@@ -0,0 +1,298 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//queryKiller dialects where cancelling the context only closes the connection and the statement
+//keeps running on the server
+type queryKiller interface {
+	connectionIDQuery() string
+	killQuery(id int64) string
+}
+
+//sqlConn statement methods of *sql.DB, *sql.Conn and *sql.Tx
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+//withTimeout returns ctx with timeout when timeout is set and ctx has no deadline
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+//killConnector opens connections that know their connection id, for dialects where cancelling
+//the context only closes the connection. A statement of a connection that is cancelled is killed
+//on the server from a connection of its own, outside the pool
+type killConnector struct {
+	driver.Connector
+	killer queryKiller
+}
+
+//dsnConnector connector of a driver without driver.DriverContext
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+//openDB returns connection pool for dsn. When dialect d does not stop statements on cancel,
+//statements are killed on the server when their context is done
+func openDB(d Dialect, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(d.DriverName(), dsn)
+	k, ok := d.(queryKiller)
+	if err != nil || !ok {
+		return db, err
+	}
+	drv := db.Driver()
+	db.Close()
+	var connector driver.Connector = dsnConnector{dsn: dsn, driver: drv}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(&killConnector{Connector: connector, killer: k}), nil
+}
+
+//Connect open connection and look up its id once. Without id the connection is returned as is
+//and cancelling only closes it
+func (c *killConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := connectionID(ctx, conn, c.killer.connectionIDQuery())
+	if err != nil {
+		logPrintln("Could not get connection id, statements can not be killed:", err)
+		return conn, nil
+	}
+	return &killConn{Conn: conn, id: id, connector: c}, nil
+}
+
+//kill stop the running statement of connection id. The connection of the statement is busy and
+//the pool can be full, the kill runs on a new connection of the driver that is closed afterwards
+func (c *killConnector) kill(id int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultPingTimeout)
+	defer cancel()
+	if err := c.exec(ctx, c.killer.killQuery(id)); err != nil {
+		logPrintln("Could not kill query:", err)
+	}
+}
+
+//exec run query on a new driver connection
+func (c *killConnector) exec(ctx context.Context, query string) error {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	e, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return errors.New("Driver connection can not exec")
+	}
+	_, err = e.ExecContext(ctx, query, nil)
+	return err
+}
+
+//connectionID returns result of query on driver connection conn
+func connectionID(ctx context.Context, conn driver.Conn, query string) (int64, error) {
+	q, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return 0, errors.New("Driver connection can not query")
+	}
+	rows, err := q.QueryContext(ctx, query, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, len(rows.Columns()))
+	if len(dest) != 1 {
+		return 0, errors.New("Connection id query returned " + strconv.Itoa(len(dest)) + " columns")
+	}
+	if err = rows.Next(dest); err != nil {
+		return 0, err
+	}
+	switch v := dest[0].(type) {
+	case int64:
+		return v, nil
+	case uint64:
+		return int64(v), nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("Invalid connection id: %v", dest[0])
+}
+
+//killConn driver connection with its id on the server. The optional driver interfaces are passed
+//on to the connection of the driver
+type killConn struct {
+	driver.Conn
+	id        int64
+	connector *killConnector
+}
+
+//watch kill the statement of the connection when ctx is done before stop is called. stop waits
+//for the kill, so the connection is not used again while it is being killed. Rows are read after
+//stop, a cancel while reading closes the connection
+func (c *killConn) watch(ctx context.Context) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			c.connector.kill(c.id)
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (c *killConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer c.watch(ctx)()
+	return q.QueryContext(ctx, query, args)
+}
+
+func (c *killConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer c.watch(ctx)()
+	return e.ExecContext(ctx, query, args)
+}
+
+func (c *killConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &killStmt{Stmt: stmt, conn: c}, nil
+}
+
+func (c *killConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *killConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("Driver does not support transaction options")
+	}
+	return c.Conn.Begin()
+}
+
+func (c *killConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *killConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *killConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *killConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+//killStmt prepared statement of a killConn
+type killStmt struct {
+	driver.Stmt
+	conn *killConn
+}
+
+func (s *killStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	defer s.conn.watch(ctx)()
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values)
+}
+
+func (s *killStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer s.conn.watch(ctx)()
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+func (s *killStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return s.conn.CheckNamedValue(nv)
+}
+
+//namedValues returns args for a statement without context, that has no named arguments
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("Driver does not support named arguments")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
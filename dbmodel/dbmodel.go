@@ -1,99 +1,110 @@
 package dbmodel
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	//used for connecting to datbase
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/jmu0/settings"
 )
 
-//Connect connect to database
+//Connect connect to database using options, ORM_* environment variables and orm.conf, see LoadConfig.
+//Caller owns the returned pool, use Open or Default for a shared Store
 func Connect(arg ...string) (*sql.DB, error) {
-	//TODO change path
-	var path string
-	path = "orm.conf"
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		path = "/etc/orm.conf"
-	}
-	set := settings.Settings{File: path}
-	database, err := set.Get("database")
+	cfg, err := LoadConfig(Config{})
 	if err != nil {
-		fmt.Println(err)
-		fmt.Printf("Database server: ")
-		fmt.Scanln(&database)
+		return nil, err
 	}
-	usr, err := set.Get("user")
+	return ConnectConfig(cfg)
+}
+
+//ConnectConfig connect to database using cfg, returns error when required values are missing
+func ConnectConfig(cfg Config) (*sql.DB, error) {
+	db, err := connectConfig(cfg)
+	return db, redactError(err)
+}
+
+func connectConfig(cfg Config) (*sql.DB, error) {
+	cfg, err := cfg.resolvePassword()
 	if err != nil {
-		fmt.Println(err)
-		fmt.Printf("Username: ")
-		fmt.Scanln(&usr)
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
-	pwd, err := set.Get("password")
+	cfg = cfg.withDefaults()
+	d, err := GetDialect(cfg.Dialect)
 	if err != nil {
-		fmt.Println(err)
-		fmt.Printf("Password: ")
-		fmt.Scanln(&pwd)
-	}
-	db, err := sql.Open("mysql", makeDSN(database, usr, pwd))
-	db.SetMaxOpenConns(50)
-	db.SetMaxIdleConns(0)
-	d, _ := time.ParseDuration("1 second")
-	db.SetConnMaxLifetime(d)
+		return nil, err
+	}
+	if p, ok := d.(poolConfigurer); ok {
+		cfg = p.poolConfig(cfg)
+	}
+	dsn, err := d.DSN(cfg)
 	if err != nil {
-		return db, err
+		return nil, err
+	}
+	db, err := openDB(d, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	if cfg.Ping {
+		if err = db.Ping(); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 	return db, nil
 }
 
-//DoQuery connects, queries and returns results
-func DoQuery(query string) ([]map[string]interface{}, error) {
+//DoQuery queries the default store and returns results, args are passed to the driver for
+//the placeholders in query
+func DoQuery(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return DoQueryContext(context.Background(), query, args...)
+}
+
+//DoQueryContext DoQuery with context, the query is stopped when ctx is done
+func DoQueryContext(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
 	var err error
-	db, err := Connect()
+	db, err := Default()
 	ret := make([]map[string]interface{}, 0)
 	if err != nil {
 		return ret, err
 	}
-	defer db.Close()
-	ret, err = Query(db, query)
+	ret, err = QueryContext(ctx, db, query, args...)
 	if err != nil {
 		return ret, err
 	}
 	return ret, nil
 }
 
-//Query Get slice of map[string]interface{} from database
-func Query(db *sql.DB, query string) ([]map[string]interface{}, error) {
-	res := make([]map[string]interface{}, 0)
-	rows, err := db.Query(query)
-	if err != nil {
-		return res, err
-	}
-	defer rows.Close()
+//Query Get slice of map[string]interface{} from database, values are strings and NULL is
+//an empty string. Use QueryTyped for typed values and nil for NULL. args are passed to the driver
+//for the placeholders in query (? for mysql and sqlite, $1 for postgres)
+func Query(db *Store, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return queryMaps(context.Background(), db, query, args...)
+}
 
-	columns, err := rows.Columns()
-	if err != nil {
-		rows.Close()
-		return res, err
-	}
-	values := make([]sql.RawBytes, len(columns))
-	scanArgs := make([]interface{}, len(values))
-	for i := range values {
-		scanArgs[i] = &values[i]
-	}
+//QueryContext Query with context, the query is stopped when ctx is done
+func QueryContext(ctx context.Context, db *Store, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return queryMaps(ctx, db, query, args...)
+}
 
-	for rows.Next() {
-		rows.Scan(scanArgs...)
+//queryMaps run query with arguments and return rows as maps
+func queryMaps(ctx context.Context, db *Store, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	res := make([]map[string]interface{}, 0)
+	err := scanRaw(ctx, db, query, args, nil, func(columns, types []string, values []sql.RawBytes) error {
 		v := make(map[string]interface{})
 		var value interface{}
 		for i, col := range values {
@@ -105,37 +116,100 @@ func Query(db *sql.DB, query string) ([]map[string]interface{}, error) {
 			v[columns[i]] = value
 		}
 		res = append(res, v)
-	}
-	if err = rows.Err(); err != nil {
-		rows.Close()
-		return res, err
-	}
+		return nil
+	})
 	//DEBUG:log.Println(res)
-	return res, nil
+	return res, err
+}
+
+//ServeQuery does query on the default store and writes json with typed values to responseWriter,
+//rows are written as they are read
+func ServeQuery(query string, w http.ResponseWriter, args ...interface{}) error {
+	return ServeQueryContext(context.Background(), query, w, args...)
 }
 
-//ServeQuery does query and writes json to responseWriter
-func ServeQuery(query string, w http.ResponseWriter) error {
-	result, err := DoQuery(query)
+//ServeQueryContext ServeQuery with context, use the request context to stop the query when the
+//client disconnects
+func ServeQueryContext(ctx context.Context, query string, w http.ResponseWriter, args ...interface{}) error {
+	db, err := Default()
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return err
 	}
-	json, err := json.Marshal(result)
-	if err != nil {
+	err = streamJSON(ctx, db, w, false, nil, query, args...)
+	if err != nil && err != errStreamed {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+	return err
+}
+
+//errStreamed returned by streamJSON when the query failed after rows were written
+var errStreamed = errors.New("Query failed after writing results")
+
+//streamJSON run query and write rows as json array to w one at a time. With unwrap a single row
+//is written as object. filter is applied to the json of each row. Errors before the first row
+//is written are returned so the caller can write an error response, errors after that are
+//logged and errStreamed is returned
+func streamJSON(ctx context.Context, db *Store, w http.ResponseWriter, unwrap bool, filter func([]byte) []byte, query string, args ...interface{}) error {
+	var first []byte
+	n := 0
+	start := func() {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte("["))
+		w.Write(first)
+	}
+	err := queryEach(ctx, db, query, args, nil, func(row Row) error {
+		bytes, err := row.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if filter != nil {
+			bytes = filter(bytes)
+		}
+		n++
+		if n == 1 {
+			first = bytes
+			if unwrap {
+				//wait for the next row to know if this is a list
+				return nil
+			}
+			start()
+			return nil
+		}
+		if n == 2 && unwrap {
+			start()
+		}
+		w.Write([]byte(","))
+		_, err = w.Write(bytes)
 		return err
+	})
+	if n == 0 || (n == 1 && unwrap) {
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if n == 0 {
+			w.Write([]byte("[]"))
+		} else {
+			w.Write(first)
+		}
+		return nil
 	}
-	// log.Println("GET in bestelling voor", lokatie)
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Write(json)
+	if err != nil {
+		//the status is sent, the client gets invalid json
+		logPrintln("streamJSON: error after writing results:", err)
+		return errStreamed
+	}
+	w.Write([]byte("]"))
 	return nil
 }
 
-//HandleREST handle REST api for DbObject
+//HandleREST (DEPRECATED) handle REST api for DbObject using the default store, use rest.NewHandler.
+//Queries are stopped when the client disconnects
 func HandleREST(pathPrefix string, w http.ResponseWriter, r *http.Request) string {
 	var objStr = r.URL.Path
-	db, err := Connect()
+	ctx := r.Context()
+	db, err := Default()
 	if err != nil {
 		http.Error(w, "REST: Could not connect to database", http.StatusInternalServerError)
 		return ""
@@ -156,20 +230,18 @@ func HandleREST(pathPrefix string, w http.ResponseWriter, r *http.Request) strin
 	}
 	//fmt.Println("REST DEBUG: objStr:", objStr)
 	oParts := strings.Split(objStr, "/")
+	d := db.Dialect()
 	var rDB, rTBL, rKey string
 	if len(oParts) > 0 {
-		rDB = Escape(oParts[0])
+		rDB = d.Escape(oParts[0])
 	}
 	if len(oParts) > 1 {
-		rTBL = Escape(oParts[1])
+		rTBL = d.Escape(oParts[1])
 	}
 	if len(oParts) > 2 {
-		//KEYS WITH / USE "
-		rKey = Escape(strings.Join(oParts[2:], "/"))
-		if rKey[:2] == "\\\"" && rKey[len(rKey)-2:] == "\\\"" {
-			rKey = rKey[2 : len(rKey)-2]
-		}
-		if rKey[:1] == "\"" && rKey[len(rKey)-1:] == "\"" {
+		//KEYS WITH / USE ", key values are passed as query arguments
+		rKey = strings.Join(oParts[2:], "/")
+		if len(rKey) > 1 && rKey[:1] == "\"" && rKey[len(rKey)-1:] == "\"" {
 			rKey = rKey[1 : len(rKey)-1]
 		}
 	}
@@ -179,11 +251,11 @@ func HandleREST(pathPrefix string, w http.ResponseWriter, r *http.Request) strin
 	case 1: //only db, write list of tables
 		if r.Method == "GET" {
 			// tbls := GetTableNames(db, objParts[0])
-			tbls := GetTableNames(db, rDB)
+			tbls := GetTableNamesContext(ctx, db, rDB)
 			if len(tbls) > 0 {
 				bytes, err := json.Marshal(tbls)
 				if err != nil {
-					fmt.Println("HandleRest: error encoding json:", err)
+					logPrintln("HandleRest: error encoding json:", err)
 					http.Error(w, "Could not encode json", http.StatusInternalServerError)
 					return ""
 				}
@@ -200,14 +272,21 @@ func HandleREST(pathPrefix string, w http.ResponseWriter, r *http.Request) strin
 	case 2: //table, query rows
 		if r.Method == "GET" {
 			// q := "select * from " + objParts[0] + "." + objParts[1]
-			q := "select * from " + rDB + "." + rTBL
-			//TODO check for query
-			if where, ok := r.URL.Query()["q"]; ok != false {
-				q += " where " + Escape(where[0])
-				q = strings.Replace(q, "''", "'", -1)
+			//password columns can not be filtered on, the where clause in q is allowed
+			cols := GetColumnsContext(ctx, db, rDB, rTBL)
+			sel, page, err := TableQuery{RawWhere: true}.Select(rDB, rTBL, d, cols, r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return ""
+			}
+			//pages only when asked for, clients of the deprecated handler expect all rows
+			params := r.URL.Query()
+			if params.Has("limit") || params.Has("offset") || params.Has("after") {
+				page.Apply(sel)
 			}
+			q, args := sel.ToSQL(d)
 			// log.Println("DEBUG: REST query:", q)
-			writeQueryResults(db, q, w)
+			writeQueryResults(ctx, db, w, q, args...)
 		} else if r.Method == "POST" { //post to a db table url
 			// cols := getColsWithValues(db, objParts[0], objParts[1], r)
 			cols := getColsWithValues(db, rDB, rTBL, r)
@@ -215,12 +294,12 @@ func HandleREST(pathPrefix string, w http.ResponseWriter, r *http.Request) strin
 				http.Error(w, "REST: Object not found", http.StatusNotFound)
 				return ""
 			}
-			log.Println("POST:", r.URL.Path)
+			logPrintln("POST:", r.URL.Path)
 			// n, id, err := save(objParts[0], objParts[1], cols)
-			n, id, err := save(rDB, rTBL, cols)
+			n, id, err := save(ctx, db, rDB, rTBL, cols)
 			if err != nil {
-				log.Println("REST ERROR: POST:", oParts, err)
-				http.Error(w, "Could not save", http.StatusInternalServerError)
+				logPrintln("REST ERROR: POST:", oParts, err)
+				restError(w, err, http.StatusInternalServerError, "Could not save")
 				return ""
 			}
 			if n == 1 && id > -1 {
@@ -244,32 +323,21 @@ func HandleREST(pathPrefix string, w http.ResponseWriter, r *http.Request) strin
 		// fmt.Println("DEBUG: HandleRest:", cols)
 		switch r.Method {
 		case "GET":
-			log.Println("REST: GET:", oParts)
+			logPrintln("REST: GET:", oParts)
 			// cols := getColsWithValues(db, objParts[0], objParts[1], r)
 			cols := getColsWithValues(db, rDB, rTBL, r)
 			//put primary key values in columns
-			// keys := strings.Split(strings.Join(objParts[2:], "/"), ":")
-			keys := strings.Split(rKey, ":")
-			keyCounter := 0
-			for index, column := range cols {
-				if column.Key == "PRI" {
-					cols[index].Value = Escape(keys[keyCounter])
-					keyCounter++
-					if keyCounter == len(keys) {
-						break
-					}
-				}
-			}
+			setKeyValues(cols, rKey)
 			// q := "select * from " + objParts[0] + "." + objParts[1] + " where "
-			q := "select * from " + rDB + "." + rTBL + " where "
-			where, err := StrPrimaryKeyWhereSQL(cols)
-			if err != nil {
+			q := "select * from " + Table(d, rDB, rTBL) + " where "
+			where, args := keyWhere(d, cols, 1)
+			if where == "" {
 				http.Error(w, "Could not build query", http.StatusInternalServerError)
 				return ""
 			}
 			q += where
 			//log.Println("REST: GET: query ", q)
-			writeQueryResults(db, q, w)
+			writeQueryResults(Prepared(ctx), db, w, q, args...)
 		case "POST": //post to a object id
 			// cols := getColsWithValues(db, objParts[0], objParts[1], r)
 			cols := getColsWithValues(db, rDB, rTBL, r)
@@ -278,26 +346,15 @@ func HandleREST(pathPrefix string, w http.ResponseWriter, r *http.Request) strin
 				return ""
 			}
 			//put primary key values in columns
-			// keys := strings.Split(strings.Join(objParts[2:], "/"), ":")
-			keys := strings.Split(rKey, ":")
-			keyCounter := 0
-			for index, column := range cols {
-				if column.Key == "PRI" {
-					cols[index].Value = Escape(keys[keyCounter])
-					keyCounter++
-					if keyCounter == len(keys) {
-						break
-					}
-				}
-			}
-			log.Println("POST:", r.URL.Path)
+			setKeyValues(cols, rKey)
+			logPrintln("POST:", r.URL.Path)
 			//log.Println("DEBUG:POST:", objParts)
 			// log.Println("DEBUG POST:", cols)
 			// n, id, err := save(objParts[0], objParts[1], cols)
-			n, id, err := save(rDB, rTBL, cols)
+			n, id, err := save(ctx, db, rDB, rTBL, cols)
 			if err != nil {
-				log.Println("REST: ERROR: POST:", oParts, err)
-				http.Error(w, "Could not save", http.StatusInternalServerError)
+				logPrintln("REST: ERROR: POST:", oParts, err)
+				restError(w, err, http.StatusInternalServerError, "Could not save")
 				return ""
 			}
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -316,29 +373,18 @@ func HandleREST(pathPrefix string, w http.ResponseWriter, r *http.Request) strin
 				return ""
 			}
 			//put primary key values in columns
-			// keys := strings.Split(strings.Join(objParts[2:], "/"), ":")
-			keys := strings.Split(rKey, ":")
-			keyCounter := 0
-			for index, column := range cols {
-				if column.Key == "PRI" {
-					cols[index].Value = Escape(keys[keyCounter])
-					keyCounter++
-					if keyCounter == len(keys) {
-						break
-					}
-				}
-			}
-			log.Println("REST: DELETE:", oParts)
+			setKeyValues(cols, rKey)
+			logPrintln("REST: DELETE:", oParts)
 			// n, err := delete(objParts[0], objParts[1], cols)
-			n, err := delete(rDB, rTBL, cols)
+			n, err := deleteRow(ctx, db, rDB, rTBL, cols)
 			if err != nil {
-				log.Println("REST: ERROR: POST:", oParts, err)
-				http.Error(w, "Could not save", http.StatusInternalServerError)
+				logPrintln("REST: ERROR: POST:", oParts, err)
+				restError(w, err, http.StatusInternalServerError, "Could not save")
 				return ""
 			}
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
 			w.Write([]byte("{\"n\":\"" + strconv.Itoa(n) + "\"}"))
-			return string(n)
+			return strconv.Itoa(n)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return ""
@@ -350,11 +396,11 @@ func HandleREST(pathPrefix string, w http.ResponseWriter, r *http.Request) strin
 	return ""
 }
 
-func getColsWithValues(db *sql.DB, dbName string, tblName string, r *http.Request) []Column {
-	cols := GetColumns(db, dbName, tblName)
+func getColsWithValues(db *Store, dbName string, tblName string, r *http.Request) []Column {
+	cols := GetColumnsContext(r.Context(), db, dbName, tblName)
 	data, err := getRequestData(r)
 	if err != nil {
-		log.Println("REST: ERROR: POST:", dbName, tblName, err)
+		logPrintln("REST: ERROR: POST:", dbName, tblName, err)
 	}
 	//set column values
 	for key, value := range data {
@@ -380,6 +426,21 @@ func cols2json(table string, cols []Column) ([]byte, error) {
 	return json, nil
 }
 
+//setKeyValues put : separated key values in the primary key columns
+func setKeyValues(cols []Column, key string) {
+	keys := strings.Split(key, ":")
+	keyCounter := 0
+	for index, column := range cols {
+		if column.Key == "PRI" {
+			cols[index].Value = keys[keyCounter]
+			keyCounter++
+			if keyCounter == len(keys) {
+				break
+			}
+		}
+	}
+}
+
 func findColIndex(field string, cols []Column) int {
 	for index, col := range cols {
 		if col.Field == field {
@@ -388,31 +449,21 @@ func findColIndex(field string, cols []Column) int {
 	}
 	return -1
 }
-func writeQueryResults(db *sql.DB, q string, w http.ResponseWriter) {
-	var ret interface{}
-	res, err := Query(db, q)
+func writeQueryResults(ctx context.Context, db *Store, w http.ResponseWriter, q string, args ...interface{}) {
 	//fmt.Println("REST: DEBUG: writeQueryResults:", q)
-	if err != nil {
-		http.Error(w, "No results found", http.StatusNotFound)
-		return
+	err := streamJSON(ctx, db, w, true, dropPasswords, q, args...)
+	if err != nil && err != errStreamed {
+		logPrintln("HandleRest: error:", err)
+		restError(w, err, http.StatusNotFound, "No results found")
 	}
-	if len(res) == 1 {
-		ret = res[0]
-	} else {
-		ret = res
-	}
-	bytes, err := json.Marshal(ret)
-	if err != nil {
-		fmt.Println("HandleRest: error encoding json:", err)
-		http.Error(w, "No results found", http.StatusNotFound)
-		return
-	}
-	//drop password fields
-	var pwReg = ",\"?([P,p]ass[W,w]o?r?d|[W,w]acht[W,w]o?o?r?d?)\"?:\"(.*?)\""
-	passwdReg := regexp.MustCompile(pwReg)
-	str := passwdReg.ReplaceAllString(string(bytes), "")
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Write([]byte(str))
+}
+
+//drop password fields
+var passwdReg = regexp.MustCompile(",\"?([P,p]ass[W,w]o?r?d|[W,w]acht[W,w]o?o?r?d?)\"?:\"(.*?)\"")
+
+//dropPasswords remove password fields from json
+func dropPasswords(bytes []byte) []byte {
+	return passwdReg.ReplaceAll(bytes, nil)
 }
 
 //getRequestData get data from post request
@@ -482,62 +533,78 @@ func ToMapSlice(slice []DbObject) []map[string]interface{} {
 	return ret
 }
 
-//Save database object using statement
+//Save database object using statement on the default store
 func Save(obj DbObject) (int, error) {
+	return SaveContext(context.Background(), obj)
+}
+
+//SaveContext Save with context, the statement is stopped when ctx is done
+func SaveContext(ctx context.Context, obj DbObject) (int, error) {
+	db, err := Default()
+	if err != nil {
+		return -1, err
+	}
 	dbName, tblName := obj.GetDbInfo()
 	cols := obj.GetColumns()
-	n, _, err := save(dbName, tblName, cols)
+	n, _, err := save(ctx, db, dbName, tblName, cols)
 	return n, err
 }
 
+//SaveColumns insert or update row of dbName.tblName with the values of cols, like Save for code
+//without a DbObject such as REST handlers. Returns rows affected and the auto increment id, -1
+//when unknown
+func SaveColumns(ctx context.Context, db *Store, dbName string, tblName string, cols []Column) (int, int, error) {
+	return save(ctx, db, dbName, tblName, cols)
+}
+
+//DeleteColumns delete row of dbName.tblName with the primary key values in cols, like Delete
+func DeleteColumns(ctx context.Context, db *Store, dbName string, tblName string, cols []Column) (int, error) {
+	return deleteRow(ctx, db, dbName, tblName, cols)
+}
+
 //save can be used by HandleREST and DbObject
-func save(dbName string, tblName string, cols []Column) (int, int, error) {
+func save(ctx context.Context, db *Store, dbName string, tblName string, cols []Column) (int, int, error) {
 	var err error
-	db, err := Connect()
-	if err != nil {
-		return -1, -1, err
-	}
-	defer db.Close()
+	defer db.invalidate(dbName, tblName)
 
-	query := "insert into " + dbName + "." + tblName + " "
-	fields := "("
-	strValues := "("
-	insValues := make([]interface{}, 0)
-	updValues := make([]interface{}, 0)
-	strUpdate := ""
+	d := db.Dialect()
+	fields, keys, values := saveFields(cols)
+	autoInc := ""
+	nrKeys := 0
 	for _, c := range cols {
 		//log.Println("DEBUG:", c)
-		if c.Value != nil {
-			if (GetType(c.Type) == "int" && c.Value == "") == false { //skip auto_increment column
-				if len(fields) > 1 {
-					fields += ", "
-				}
-				fields += c.Field
-				if len(strValues) > 1 {
-					strValues += ", "
-				}
-				strValues += "?"
-				insValues = append(insValues, c.Value)
-				if len(strUpdate) > 0 {
-					strUpdate += ", "
-				}
-				strUpdate += c.Field + "=?"
-				updValues = append(updValues, c.Value)
-			}
+		if strings.Contains(c.Extra, "auto_increment") {
+			autoInc = c.Field
+		}
+		if c.Key == "PRI" {
+			nrKeys++
 		}
 	}
-	fields += ")"
-	strValues += ")"
-	query += fields + " values " + strValues
-	query += " on duplicate key update " + strUpdate
+	query, args := d.Upsert(Table(d, dbName, tblName), fields, keys, values)
+	//saving again gives the same result only when the complete primary key is known
+	retry := func(fn func() error) error { return fn() }
+	if len(keys) > 0 && len(keys) == nrKeys {
+		retry = func(fn func() error) error { return db.retryWrite(ctx, fn) }
+	}
+	if returning := d.Returning(autoInc); returning != "" {
+		//id is returned by the insert statement
+		var id int
+		err = retry(func() error {
+			return db.queryRow(Prepared(ctx), query+returning, args, &id)
+		})
+		if err == sql.ErrNoRows {
+			return 0, -1, nil
+		} else if err != nil {
+			return -1, -1, redactError(err)
+		}
+		return 1, id, nil
+	}
 	// log.Println("DEBUG SAVE query:", query)
-	insValues = append(insValues, updValues...)
-	qr, err := db.Exec(query, insValues...)
-	// stmt, err := db.Prepare(query)
-	// if err != nil {
-	// 	return -1, -1, err
-	// }
-	// qr, err := stmt.Exec(insValues...)
+	var qr sql.Result
+	err = retry(func() error {
+		qr, err = db.exec(Prepared(ctx), query, args...)
+		return err
+	})
 	if err != nil {
 		return -1, -1, err
 	}
@@ -554,15 +621,33 @@ func save(dbName string, tblName string, cols []Column) (int, int, error) {
 	return int(n), int(id), nil
 }
 
-//SaveQuery (DEPRECATED) Save database object to database (insert or update) using insert query
+//saveFields returns columns with a value and their values, and the primary key columns among them
+func saveFields(cols []Column) ([]string, []string, []interface{}) {
+	fields := make([]string, 0)
+	keys := make([]string, 0)
+	values := make([]interface{}, 0)
+	for _, c := range cols {
+		if c.Value != nil {
+			if (GetType(c.Type) == "int" && c.Value == "") == false { //skip auto_increment column
+				fields = append(fields, c.Field)
+				values = append(values, c.Value)
+				if c.Key == "PRI" {
+					keys = append(keys, c.Field)
+				}
+			}
+		}
+	}
+	return fields, keys, values
+}
+
+//SaveQuery (DEPRECATED) Save database object to database (insert or update) using insert query, mysql only
 func SaveQuery(obj DbObject) (int, error) {
 	dbName, tblName := obj.GetDbInfo()
 	cols := obj.GetColumns()
-	db, err := Connect()
+	db, err := Default()
 	if err != nil {
 		return 1, err
 	}
-	defer db.Close()
 	query := "insert into " + dbName + "." + tblName + " "
 	fields := "("
 	values := "("
@@ -582,39 +667,52 @@ func SaveQuery(obj DbObject) (int, error) {
 	values += ") "
 	query += fields + " values " + values
 	query += " on duplicate key update " + update
-	_, err = db.Exec(query)
+	_, err = db.exec(context.Background(), query)
+	db.invalidate(dbName, tblName)
 	if err != nil {
 		return 1, err
 	}
 	return 0, nil
 }
 
-//Delete database object from database
+//Delete database object from the default store
 func Delete(obj DbObject) (int, error) {
-	dbName, tblName := obj.GetDbInfo()
-	cols := obj.GetColumns()
-	return delete(dbName, tblName, cols)
+	return DeleteContext(context.Background(), obj)
 }
 
-func delete(dbName string, tblName string, cols []Column) (int, error) {
-	db, err := Connect()
+//DeleteContext Delete with context, the statement is stopped when ctx is done
+func DeleteContext(ctx context.Context, obj DbObject) (int, error) {
+	db, err := Default()
 	if err != nil {
 		return 1, err
 	}
-	defer db.Close()
-	query := "delete from " + dbName + "." + tblName + " where"
-	where, err := StrPrimaryKeyWhereSQL(cols)
-	if err != nil {
-		fmt.Println("error:", err)
+	dbName, tblName := obj.GetDbInfo()
+	cols := obj.GetColumns()
+	return deleteRow(ctx, db, dbName, tblName, cols)
+}
+
+//deleteRow delete row of dbName.tblName with the primary key values in cols
+func deleteRow(ctx context.Context, db *Store, dbName string, tblName string, cols []Column) (int, error) {
+	defer db.invalidate(dbName, tblName)
+	d := db.Dialect()
+	query := "delete from " + Table(d, dbName, tblName) + " where "
+	where, args := keyWhere(d, cols, 1)
+	if where == "" {
+		return 1, errors.New("No primary key for " + dbName + "." + tblName)
 	}
 	query += where
-	res, err := db.Exec(query)
+	var res sql.Result
+	err := db.retryWrite(ctx, func() error {
+		var err error
+		res, err = db.exec(Prepared(ctx), query, args...)
+		return err
+	})
 	if err != nil {
 		return 1, err
 	}
 	nrrows, _ := res.RowsAffected()
 	if nrrows < 1 {
-		return 1, errors.New("No rows deleted")
+		return 1, notFound(errors.New("No rows deleted"))
 	}
 	return 0, nil
 }
@@ -631,26 +729,24 @@ func valueString(val interface{}) string {
 	case int, int32, int64:
 		value += strconv.Itoa(val.(int))
 	default:
-		fmt.Println(t)
+		logPrintln(t)
 		value += "\"" + Escape(val.(string)) + "\""
 	}
 	return value
 }
 
-//returns connection string for database driver
-func makeDSN(server, user, password string) string {
-	var port string
-	port = "3306"
-	return user + ":" + password + "@tcp(" + server + ":" + port + ")/"
+//GetDatabaseNames Get database names from server
+func GetDatabaseNames(db *Store) []string {
+	return GetDatabaseNamesContext(context.Background(), db)
 }
 
-//GetDatabaseNames Get database names from server
-func GetDatabaseNames(db *sql.DB) []string {
+//GetDatabaseNamesContext GetDatabaseNames with context
+func GetDatabaseNamesContext(ctx context.Context, db *Store) []string {
 	dbs := []string{}
-	query := "show databases"
-	rows, err := db.Query(query)
-	defer rows.Close()
+	query := db.Dialect().DatabasesQuery()
+	rows, done, err := db.query(ctx, query)
 	if err == nil && rows != nil {
+		defer done()
 		dbName := ""
 		for rows.Next() {
 			rows.Scan(&dbName)
@@ -680,35 +776,47 @@ func skipDb(name string) bool {
 }
 
 //GetTableNames Get table names from database
-func GetTableNames(db *sql.DB, dbName string) []string {
+func GetTableNames(db *Store, dbName string) []string {
+	return GetTableNamesContext(context.Background(), db, dbName)
+}
+
+//GetTableNamesContext GetTableNames with context
+func GetTableNamesContext(ctx context.Context, db *Store, dbName string) []string {
 	tbls := []string{}
-	query := "show tables in " + dbName
-	rows, err := db.Query(query)
+	query, args := db.Dialect().TablesQuery(dbName)
+	rows, done, err := db.query(ctx, query, args...)
 	if err != nil {
 		return tbls
 	} else if rows != nil {
+		defer done()
 		tableName := ""
 		for rows.Next() {
 			rows.Scan(&tableName)
 			tbls = append(tbls, tableName)
 		}
 	}
-	defer rows.Close()
 	return tbls
 }
 
 //GetColumns get list of columns from database table
-func GetColumns(db *sql.DB, dbName string, tableName string) []Column {
+func GetColumns(db *Store, dbName string, tableName string) []Column {
+	return GetColumnsContext(context.Background(), db, dbName, tableName)
+}
+
+//GetColumnsContext GetColumns with context
+func GetColumnsContext(ctx context.Context, db *Store, dbName string, tableName string) []Column {
 	cols := []Column{}
 	var col Column
-	query := "show columns from " + dbName + "." + tableName
-	//TODO: waarom zie ik geen auto_increment in kolom Extra??
-	rows, err := db.Query(query)
-	defer rows.Close()
+	query, args := db.Dialect().ColumnsQuery(dbName, tableName)
+	rows, done, err := db.query(ctx, query, args...)
 	if err == nil && rows != nil {
+		defer done()
 		for rows.Next() {
 			col = Column{}
-			rows.Scan(&col.Field, &col.Type, &col.Null, &col.Key, &col.Default, &col.Extra)
+			//Default can be NULL, scanning NULL into a string stops the scan before Extra
+			var def sql.NullString
+			rows.Scan(&col.Field, &col.Type, &col.Null, &col.Key, &def, &col.Extra)
+			col.Default = def.String
 			// fmt.Println("DEBUG:",rows)
 			// fmt.Println("DEBUG GetColumns:", col)
 			cols = append(cols, col)
@@ -738,8 +846,9 @@ func GetType(t string) string {
 		"smallint": "int",
 		"datetime": "string",
 		"int":      "int",
+		"integer":  "int", //postgres
 	}
-	t = strings.Split(t, "(")[0]
+	t = strings.ToLower(strings.Split(t, "(")[0])
 	if tp, ok := dataTypes[t]; ok {
 		return tp
 	}
@@ -749,7 +858,7 @@ func GetType(t string) string {
 func setAutoIncColumn(id int, cols []Column) []Column {
 	//fmt.Println("DEBUG:setAutoIncColumn")
 	for index, col := range cols {
-		if strings.Contains(col.Type, "int") && col.Key == "PRI" {
+		if GetType(col.Type) == "int" && col.Key == "PRI" {
 			//fmt.Println("DEBUG:found", col.Field)
 			cols[index].Value = id
 		}
@@ -768,13 +877,14 @@ func hasIntColumns(cols []Column) bool {
 }
 
 //CreateObject create object from db/table
-func CreateObject(db *sql.DB, dbName, tblName string) error {
+func CreateObject(db *Store, dbName, tblName string) error {
 	var code string
 	var importPrefix = "github.com/jmu0/orm/"
 	cols := GetColumns(db, dbName, tblName)
 
 	code += "package " + strings.ToLower(tblName) + "\n\n"
 	code += "import (\n\t\"" + importPrefix + "dbmodel\"\n"
+	code += "\t\"context\"\n"
 	code += "\t\"errors\"\n"
 	if hasIntColumns(cols) {
 		code += "\t\"strconv\"\n"
@@ -782,13 +892,13 @@ func CreateObject(db *sql.DB, dbName, tblName string) error {
 	code += ")\n\n"
 	code += "type " + tblName + " struct {\n"
 	for _, col := range cols {
-		code += "\t" + strings.ToUpper(col.Field[:1]) + col.Field[1:] + " " + GetType(col.Type) + "\n"
+		code += "\t" + strings.ToUpper(col.Field[:1]) + col.Field[1:] + " " + GetType(col.Type) + " `db:\"" + col.Field + "\"`\n"
 	}
 	code += "}\n\n"
 	code += "func (" + strings.ToLower(tblName[:1]) + " *" + tblName + ") GetDbInfo() (dbName string, tblName string) {\n"
 	code += "\treturn \"" + dbName + "\", \"" + tblName + "\"\n"
 	code += "}\n\n"
-	code += strGetQueryFunction(cols, dbName, tblName)
+	code += strGetQueryFunction(cols, Table(db.Dialect(), dbName, tblName), tblName)
 	code += strGetSaveFunction(cols, dbName, tblName)
 	code += strGetDeleteFunction(cols, dbName, tblName)
 	code += strGetGetFunction(cols, tblName)
@@ -886,6 +996,12 @@ func strGetSaveFunction(c []Column, dbName string, tblName string) string {
 	ret = "func (" + strings.ToLower(tblName[:1]) + " *" + tblName + ") Save() (Nr int, err error) {\n"
 	ret += "\treturn dbmodel.Save(" + strings.ToLower(tblName[:1]) + ")\n"
 	ret += "}\n\n"
+	ret += "func (" + strings.ToLower(tblName[:1]) + " *" + tblName + ") SaveContext(ctx context.Context) (Nr int, err error) {\n"
+	ret += "\treturn dbmodel.SaveContext(ctx, " + strings.ToLower(tblName[:1]) + ")\n"
+	ret += "}\n\n"
+	ret += "func (" + strings.ToLower(tblName[:1]) + " *" + tblName + ") SaveTx(tx *dbmodel.Tx) (Nr int, err error) {\n"
+	ret += "\treturn tx.Save(" + strings.ToLower(tblName[:1]) + ")\n"
+	ret += "}\n\n"
 	return ret
 }
 
@@ -894,51 +1010,71 @@ func strGetDeleteFunction(c []Column, dbName string, tblName string) string {
 	ret = "func (" + strings.ToLower(tblName[:1]) + " *" + tblName + ") Delete() (Nr int, err error) {\n"
 	ret += "\treturn dbmodel.Delete(" + strings.ToLower(tblName[:1]) + ")\n"
 	ret += "}\n\n"
+	ret += "func (" + strings.ToLower(tblName[:1]) + " *" + tblName + ") DeleteContext(ctx context.Context) (Nr int, err error) {\n"
+	ret += "\treturn dbmodel.DeleteContext(ctx, " + strings.ToLower(tblName[:1]) + ")\n"
+	ret += "}\n\n"
+	ret += "func (" + strings.ToLower(tblName[:1]) + " *" + tblName + ") DeleteTx(tx *dbmodel.Tx) (Nr int, err error) {\n"
+	ret += "\treturn tx.Delete(" + strings.ToLower(tblName[:1]) + ")\n"
+	ret += "}\n\n"
 	return ret
 }
 
-func strGetQueryFunction(cols []Column, dbName string, tblName string) string {
-	//TODO: with this code integer fields cannot be null. change to check for ""
+func strGetQueryFunction(cols []Column, table string, tblName string) string {
 	var ret string
-	ret = "func Query(where string, orderby string) ([]" + tblName + ", error) {\n"
-	ret += "\tquery := \"select * from " + dbName + "." + tblName + "\"\n"
+	ret = "//Query get rows, where may contain placeholders for args and an order by clause\n"
+	ret += "func Query(where string, args ...interface{}) ([]" + tblName + ", error) {\n"
+	ret += "\treturn QueryContext(context.Background(), where, args...)\n"
+	ret += "}\n\n"
+	ret += "//QueryContext Query with context\n"
+	ret += "func QueryContext(ctx context.Context, where string, args ...interface{}) ([]" + tblName + ", error) {\n"
+	ret += "\tquery := " + strconv.Quote("select * from "+table) + "\n"
 	ret += "\tif len(where) > 0 {\n\t\tquery += \" where \" + where\n\t}\n"
-	ret += "\tif len(orderby) > 0 {\n\t\tquery += \" order by \" + orderby\n\t}\n"
 	ret += "\tret := []" + tblName + "{}\n"
-	ret += "\tdb, err := dbmodel.Connect()\n"
-	ret += "\tdefer db.Close()\n"
+	ret += "\tif err := dbmodel.CheckArgs(where, args); err != nil {\n\t\treturn ret, err\n\t}\n"
+	ret += "\tdb, err := dbmodel.Default()\n"
 	ret += "\tif err != nil {\n\t\treturn ret, err\n\t}\n"
-	ret += "\tres,err := dbmodel.Query(db, query)\n"
+	ret += "\tret, err = dbmodel.Fetch[" + tblName + "](ctx, db, query, args...)\n"
 	ret += "\tif err != nil {\n\t\treturn ret, err\n\t}\n"
-	ret += "\tfor _, r := range res {\n"
-	if hasIntColumns(cols) {
-		ret += "\t\tvar err error\n"
-	}
-	ret += "\t\tobj := " + tblName + "{}\n"
-	for _, c := range cols {
-		tp := GetType(c.Type)
-		if tp == "int" {
-			// ret += "\t\tobj." + strings.ToUpper(c.Field[:1]) + c.Field[1:] + " = "
-			// ret += "r[\"" + c.Field + "\"].(int)\n"
-			ret += "\t\tobj." + strings.ToUpper(c.Field[:1]) + c.Field[1:] + ", err = "
-			ret += "strconv.Atoi(r[\"" + c.Field + "\"].(string))\n"
-			ret += "\t\tif err != nil && r[\"" + c.Field + "\"] != \"NULL\" && r[\"" + c.Field + "\"] != \"\""
-			ret += " {\n\t\t\treturn ret, err\n\t\t}\n"
-		} else {
-			ret += "\t\tif r[\"" + c.Field + "\"] != nil {\n"
-			ret += "\t\t\tobj." + strings.ToUpper(c.Field[:1]) + c.Field[1:] + " = "
-			ret += "r[\"" + c.Field + "\"].(string)\n"
-			ret += "\t\t}\n"
-		}
-	}
-	ret += "\t\tret = append(ret, obj)\n"
-	ret += "\t}\n"
-	ret += "\tif len(ret) == 0 {\n\t\treturn ret, errors.New(\"No rows found\")\n\t}\n"
+	ret += "\tif len(ret) == 0 {\n\t\treturn ret, dbmodel.ErrNotFound\n\t}\n"
 	ret += "\treturn ret, nil\n"
 	ret += "}\n\n"
 	return ret
 }
 
+//CheckArgs returns error when the number of placeholders (? or $n) in where is not the number of
+//args. The generated Query used to take an order by as second argument, that would be passed as
+//argument and silently change the result
+func CheckArgs(where string, args []interface{}) error {
+	n := 0
+	var quote byte
+	for i := 0; i < len(where); i++ {
+		c := where[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+		case c == '$':
+			j := i + 1
+			for j < len(where) && where[j] >= '0' && where[j] <= '9' {
+				j++
+			}
+			if nr, err := strconv.Atoi(where[i+1 : j]); err == nil && nr > n {
+				n = nr
+			}
+			i = j - 1
+		}
+	}
+	if n != len(args) {
+		return errors.New("Where has " + strconv.Itoa(n) + " placeholders and " + strconv.Itoa(len(args)) + " arguments, put order by in where: Query(\"a = ? order by b\", 1)")
+	}
+	return nil
+}
+
 //StrPrimaryKeyWhereSQL returns where part of query
 func StrPrimaryKeyWhereSQL(cols []Column) (string, error) {
 	var ret string
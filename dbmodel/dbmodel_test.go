@@ -0,0 +1,28 @@
+package dbmodel
+
+import (
+	"testing"
+)
+
+func TestCheckArgs(t *testing.T) {
+	tests := []struct {
+		where string
+		args  int
+		ok    bool
+	}{
+		{"", 0, true},
+		{"status = ? order by created desc", 1, true},
+		{"a = $1 and b = $2 or c = $1", 2, true},
+		{"name = '?' and id = ?", 1, true},
+		{"`what?` = ?", 1, true},
+		//the old Query(where, orderby)
+		{"status = 'open'", 1, false},
+		{"a = ? and b = ?", 1, false},
+	}
+	for _, tt := range tests {
+		err := CheckArgs(tt.where, make([]interface{}, tt.args))
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckArgs(%q, %d args) = %v", tt.where, tt.args, err)
+		}
+	}
+}
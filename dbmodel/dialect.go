@@ -0,0 +1,226 @@
+package dbmodel
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//Dialect sql differences between database servers
+type Dialect interface {
+	Name() string
+	DriverName() string
+	DefaultPort() int
+	Validate(c Config) error //check for missing required values
+	DSN(c Config) (string, error)
+
+	DatabasesQuery() string                                      //returns one column with database names
+	TablesQuery(dbName string) (string, []interface{})           //returns one column with table names
+	ColumnsQuery(dbName, tblName string) (string, []interface{}) //returns Field, Type, Null, Key, Default, Extra
+
+	Quote(ident string) string //quote identifier
+	Placeholder(n int) string  //placeholder for the nth (1-based) argument
+	Escape(str string) string  //escape string for use in a literal
+
+	//Upsert returns insert or update statement for table (already quoted) and its arguments
+	Upsert(table string, fields []string, keys []string, values []interface{}) (string, []interface{})
+	//Returning returns clause that makes an insert return the auto increment column, empty when
+	//the id is available from sql.Result.LastInsertId
+	Returning(field string) string
+}
+
+//batchUpserter implemented by dialects that insert or update several rows with one statement
+type batchUpserter interface {
+	//upsertRows returns statement for rows rows of fields, the arguments are the values row by row
+	upsertRows(table string, fields []string, keys []string, rows int) string
+	maxArgs() int           //max arguments in a statement
+	maxPacketQuery() string //returns max statement size in bytes, empty when there is no limit
+}
+
+//poolConfigurer implemented by dialects that need different connection pool settings
+type poolConfigurer interface {
+	poolConfig(c Config) Config
+}
+
+var (
+	dialects     = make(map[string]Dialect)
+	dialectMutex sync.RWMutex
+)
+
+func init() {
+	RegisterDialect(MySQL{})
+}
+
+//RegisterDialect make dialect available by name for Config.Dialect
+func RegisterDialect(d Dialect) {
+	dialectMutex.Lock()
+	defer dialectMutex.Unlock()
+	dialects[d.Name()] = d
+}
+
+//GetDialect returns registered dialect, empty name is mysql
+func GetDialect(name string) (Dialect, error) {
+	if name == "" {
+		name = "mysql"
+	}
+	dialectMutex.RLock()
+	defer dialectMutex.RUnlock()
+	if d, ok := dialects[name]; ok {
+		return d, nil
+	}
+	return nil, errors.New("Unknown dialect: " + name)
+}
+
+//validateServer check for missing host and user of a database server
+func validateServer(c Config) error {
+	if c.Host == "" && c.Socket == "" {
+		return errors.New("Missing database host or socket")
+	}
+	if c.User == "" {
+		return errors.New("Missing database user")
+	}
+	return nil
+}
+
+//Table returns quoted dbName.tblName
+func Table(d Dialect, dbName, tblName string) string {
+	return d.Quote(dbName) + "." + d.Quote(tblName)
+}
+
+//placeholders returns comma separated placeholders for arguments from..from+n-1
+func placeholders(d Dialect, from, n int) string {
+	ret := make([]string, n)
+	for i := range ret {
+		ret[i] = d.Placeholder(from + i)
+	}
+	return strings.Join(ret, ", ")
+}
+
+//keyWhere returns where clause on the primary key columns, placeholders start at from
+func keyWhere(d Dialect, cols []Column, from int) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	for _, c := range cols {
+		if c.Key == "PRI" {
+			where = append(where, d.Quote(c.Field)+" = "+d.Placeholder(from+len(args)))
+			args = append(args, c.Value)
+		}
+	}
+	return strings.Join(where, " and "), args
+}
+
+//MySQL dialect for MySQL and MariaDB
+type MySQL struct{}
+
+//Name returns dialect name
+func (MySQL) Name() string {
+	return "mysql"
+}
+
+//DriverName returns database/sql driver name
+func (MySQL) DriverName() string {
+	return "mysql"
+}
+
+//DefaultPort returns default server port
+func (MySQL) DefaultPort() int {
+	return 3306
+}
+
+//Validate check for missing server and user
+func (MySQL) Validate(c Config) error {
+	return validateServer(c)
+}
+
+//DSN returns connection string for database driver
+func (MySQL) DSN(c Config) (string, error) {
+	return makeDSN(c)
+}
+
+//DatabasesQuery returns query for database names
+func (MySQL) DatabasesQuery() string {
+	return "show databases"
+}
+
+//TablesQuery returns query for table names
+func (m MySQL) TablesQuery(dbName string) (string, []interface{}) {
+	return "show tables in " + m.Quote(dbName), nil
+}
+
+//ColumnsQuery returns query for columns
+func (m MySQL) ColumnsQuery(dbName, tblName string) (string, []interface{}) {
+	return "show columns from " + Table(m, dbName, tblName), nil
+}
+
+//Quote quote identifier with backticks
+func (MySQL) Quote(ident string) string {
+	return "`" + strings.Replace(ident, "`", "``", -1) + "`"
+}
+
+//Placeholder returns ?
+func (MySQL) Placeholder(n int) string {
+	return "?"
+}
+
+//Escape string using backslash escapes
+func (MySQL) Escape(str string) string {
+	return Escape(str)
+}
+
+//Upsert returns insert ... on duplicate key update statement
+func (m MySQL) Upsert(table string, fields []string, keys []string, values []interface{}) (string, []interface{}) {
+	quoted := make([]string, len(fields))
+	update := make([]string, len(fields))
+	for i, f := range fields {
+		quoted[i] = m.Quote(f)
+		update[i] = m.Quote(f) + "=?"
+	}
+	query := "insert into " + table + " (" + strings.Join(quoted, ", ") + ") values (" + placeholders(m, 1, len(fields)) + ")"
+	query += " on duplicate key update " + strings.Join(update, ", ")
+	args := append(append([]interface{}{}, values...), values...)
+	return query, args
+}
+
+//upsertRows returns insert ... values (...), (...) on duplicate key update statement
+func (m MySQL) upsertRows(table string, fields []string, keys []string, rows int) string {
+	quoted := make([]string, len(fields))
+	update := make([]string, len(fields))
+	for i, f := range fields {
+		quoted[i] = m.Quote(f)
+		//values() is deprecated in mysql 8.0.20, the row alias that replaces it is not supported by mariadb
+		update[i] = m.Quote(f) + "=values(" + m.Quote(f) + ")"
+	}
+	values := make([]string, rows)
+	for i := range values {
+		values[i] = "(" + placeholders(m, 1, len(fields)) + ")"
+	}
+	query := "insert into " + table + " (" + strings.Join(quoted, ", ") + ") values " + strings.Join(values, ", ")
+	return query + " on duplicate key update " + strings.Join(update, ", ")
+}
+
+//maxArgs returns max placeholders of a prepared statement
+func (MySQL) maxArgs() int {
+	return 65535
+}
+
+//maxPacketQuery returns query for max_allowed_packet
+func (MySQL) maxPacketQuery() string {
+	return "select @@max_allowed_packet"
+}
+
+//Returning returns empty string, mysql has LastInsertId
+func (MySQL) Returning(field string) string {
+	return ""
+}
+
+//connectionIDQuery returns query for the id of the connection
+func (MySQL) connectionIDQuery() string {
+	return "select connection_id()"
+}
+
+//killQuery returns statement that stops the running statement of connection id, closing the
+//connection on cancel does not stop it
+func (MySQL) killQuery(id int64) string {
+	return "kill query " + strconv.FormatInt(id, 10)
+}
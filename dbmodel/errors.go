@@ -0,0 +1,142 @@
+package dbmodel
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//kinds of database errors, use errors.Is(err, ErrDuplicateKey) and errors.As(err, &dbErr) for the key
+var (
+	ErrNotFound     = errors.New("No rows found")
+	ErrDuplicateKey = errors.New("Duplicate key")
+	ErrForeignKey   = errors.New("Foreign key constraint failed")
+	ErrDataTooLong  = errors.New("Data too long")
+	ErrDeadlock     = errors.New("Deadlock")
+	ErrLockTimeout  = errors.New("Lock wait timeout")
+)
+
+//mysql error numbers by kind of error
+var mysqlErrors = map[uint16]error{
+	1062: ErrDuplicateKey, //duplicate entry for key
+	1586: ErrDuplicateKey, //duplicate entry for key, with key name
+	1216: ErrForeignKey,   //cannot add or update a child row
+	1217: ErrForeignKey,   //cannot delete or update a parent row
+	1451: ErrForeignKey,   //cannot delete or update a parent row, with constraint
+	1452: ErrForeignKey,   //cannot add or update a child row, with constraint
+	1406: ErrDataTooLong,  //data too long for column
+	1213: ErrDeadlock,     //deadlock found when trying to get lock
+	1205: ErrLockTimeout,  //lock wait timeout exceeded
+}
+
+//key, constraint or column named in mysql messages
+var (
+	errorKeyReg        = regexp.MustCompile("for key '([^']*)'")
+	errorConstraintReg = regexp.MustCompile("CONSTRAINT `([^`]*)`")
+	errorColumnReg     = regexp.MustCompile("for column '([^']*)'")
+)
+
+//errorKinds functions that return an error of another driver than mysql as *Error when it is of
+//a known kind, nil otherwise. Added by the dialects from init with registerErrorKind
+var errorKinds []func(err error) *Error
+
+//registerErrorKind add f to the errorKinds, only call from init
+func registerErrorKind(f func(err error) *Error) {
+	errorKinds = append(errorKinds, f)
+}
+
+//Error database error of a known kind, errors.Is(err, Kind) is true. Unwrap returns the driver error
+type Error struct {
+	Kind error  //ErrNotFound, ErrDuplicateKey, ErrForeignKey, ErrDataTooLong, ErrDeadlock or ErrLockTimeout
+	Key  string //name of the key, foreign key constraint or column when the server reports it
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+//Is true when target is the kind of e
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+//notFound returns err as ErrNotFound
+func notFound(err error) error {
+	return &Error{Kind: ErrNotFound, Err: err}
+}
+
+//dbError returns err with secrets removed from the message, as *Error when it is of a known kind
+func dbError(err error) error {
+	err = redactError(err)
+	if err == nil {
+		return nil
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		if kind, ok := mysqlErrors[myErr.Number]; ok {
+			return &Error{Kind: kind, Key: errorKey(kind, myErr.Message), Err: err}
+		}
+		return err
+	}
+	for _, f := range errorKinds {
+		if e := f(err); e != nil {
+			return e
+		}
+	}
+	return err
+}
+
+//errorKey returns key, constraint or column of kind from mysql message
+func errorKey(kind error, msg string) string {
+	reg := errorKeyReg
+	switch kind {
+	case ErrForeignKey:
+		reg = errorConstraintReg
+	case ErrDataTooLong:
+		reg = errorColumnReg
+	}
+	if m := reg.FindStringSubmatch(msg); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+//ErrorStatus returns http status and message for err, status and msg when it is not of a known kind.
+//The message does not contain the error of the server
+func ErrorStatus(err error, status int, msg string) (int, string) {
+	var dbErr *Error
+	if !errors.As(err, &dbErr) {
+		return status, msg
+	}
+	text := dbErr.Kind.Error()
+	if dbErr.Key != "" {
+		text += ": " + dbErr.Key
+	}
+	switch dbErr.Kind {
+	case ErrNotFound:
+		return http.StatusNotFound, text
+	case ErrDuplicateKey, ErrForeignKey:
+		return http.StatusConflict, text
+	case ErrDataTooLong:
+		return http.StatusUnprocessableEntity, text
+	case ErrDeadlock, ErrLockTimeout:
+		return http.StatusServiceUnavailable, text
+	}
+	return status, msg
+}
+
+//restError write error response for err, see ErrorStatus. Deadlocks and lock timeouts can be retried
+func restError(w http.ResponseWriter, err error, status int, msg string) {
+	status, text := ErrorStatus(err, status, msg)
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	http.Error(w, text, status)
+}
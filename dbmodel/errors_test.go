@@ -0,0 +1,87 @@
+package dbmodel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+func TestDBError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+		key  string
+	}{
+		{"mysql duplicate", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a' for key 'email'"}, ErrDuplicateKey, "email"},
+		{"mysql foreign key", &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`shop`.`lines`, CONSTRAINT `lines_order` FOREIGN KEY (`order_id`) REFERENCES `orders` (`id`))"}, ErrForeignKey, "lines_order"},
+		{"mysql too long", &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'name' at row 1"}, ErrDataTooLong, "name"},
+		{"mysql deadlock", &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, ErrDeadlock, ""},
+		{"mysql lock timeout", &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, ErrLockTimeout, ""},
+		{"postgres duplicate", &pq.Error{Code: "23505", Constraint: "orders_pkey"}, ErrDuplicateKey, "orders_pkey"},
+		{"postgres too long", &pq.Error{Code: "22001", Column: "name"}, ErrDataTooLong, "name"},
+		{"postgres deadlock", &pq.Error{Code: "40P01"}, ErrDeadlock, ""},
+	}
+	for _, tt := range tests {
+		err := dbError(tt.err)
+		var dbErr *Error
+		if !errors.As(err, &dbErr) || !errors.Is(err, tt.kind) || dbErr.Key != tt.key {
+			t.Errorf("%s: got %#v", tt.name, err)
+			continue
+		}
+		if !errors.Is(err, tt.err) || err.Error() != tt.err.Error() {
+			t.Errorf("%s: driver error not wrapped: %v", tt.name, err)
+		}
+	}
+	other := &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}
+	if err := dbError(other); err != other {
+		t.Error("Expected unknown error as is, got", err)
+	}
+	if dbError(nil) != nil {
+		t.Error("Expected nil")
+	}
+}
+
+func TestSQLiteErrors(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t, "create table t (id integer primary key, email text unique)")
+	cols := GetColumns(db, "main", "t")
+	if _, _, err := save(ctx, db, "main", "t", withValues(cols, map[string]interface{}{"id": 1, "email": "a"})); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := save(ctx, db, "main", "t", withValues(cols, map[string]interface{}{"id": 2, "email": "a"}))
+	var dbErr *Error
+	if !errors.As(err, &dbErr) || dbErr.Kind != ErrDuplicateKey || dbErr.Key != "t.email" {
+		t.Error("Expected duplicate key t.email, got", err)
+	}
+	_, err = deleteRow(ctx, db, "main", "t", withValues(cols, map[string]interface{}{"id": 3}))
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("Expected not found, got", err)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		msg    string
+	}{
+		{&Error{Kind: ErrNotFound, Err: errors.New("x")}, http.StatusNotFound, "No rows found"},
+		{&Error{Kind: ErrDuplicateKey, Key: "email", Err: errors.New("x")}, http.StatusConflict, "Duplicate key: email"},
+		{&Error{Kind: ErrForeignKey, Err: errors.New("x")}, http.StatusConflict, "Foreign key constraint failed"},
+		{&Error{Kind: ErrDataTooLong, Key: "name", Err: errors.New("x")}, http.StatusUnprocessableEntity, "Data too long: name"},
+		{&Error{Kind: ErrDeadlock, Err: errors.New("x")}, http.StatusServiceUnavailable, "Deadlock"},
+		{&Error{Kind: ErrLockTimeout, Err: errors.New("x")}, http.StatusServiceUnavailable, "Lock wait timeout"},
+		{errors.New("Syntax error near password"), http.StatusInternalServerError, "Could not save"},
+	}
+	for _, tt := range tests {
+		status, msg := ErrorStatus(tt.err, http.StatusInternalServerError, "Could not save")
+		if status != tt.status || msg != tt.msg {
+			t.Errorf("ErrorStatus(%v) = %d %q, want %d %q", tt.err, status, msg, tt.status, tt.msg)
+		}
+	}
+}
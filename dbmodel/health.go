@@ -0,0 +1,158 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//default retry policy values
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = 5 * time.Second
+	defaultPingTimeout     = 5 * time.Second
+)
+
+//mysql error numbers for errors that go away when the statement is sent again
+var transientErrors = map[uint16]bool{
+	1040: true, //too many connections
+	1053: true, //server shutdown in progress
+	2006: true, //server has gone away
+	2013: true, //lost connection during query
+}
+
+//isTransient true when err is a connection error that may succeed on retry
+func isTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if isConnError(err) {
+		return true
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return transientErrors[myErr.Number]
+	}
+	return strings.Contains(err.Error(), "server has gone away")
+}
+
+//notSent true when err happened before the statement was sent to the server, so a write that
+//failed with it did not change anything
+func notSent(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == 1040
+}
+
+//backoff returns delay before retry attempt (0-based): exponential with jitter, capped at RetryMaxBackoff
+func (c Config) backoff(attempt int) time.Duration {
+	d := c.RetryBackoff
+	for i := 0; i < attempt && d < c.RetryMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > c.RetryMaxBackoff {
+		d = c.RetryMaxBackoff
+	}
+	//between half and the full delay, so clients that failed together do not retry together
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+//retry run fn, retrying up to cfg.Retries times with backoff while fn returns a transient error
+//and ctx is not done. Only use for reads
+func (s *Store) retry(ctx context.Context, fn func() error) error {
+	return s.retryWhile(ctx, isTransient, fn)
+}
+
+//retryWrite retry for writes, only errors that happened before the statement was sent are
+//retried. A write that lost its connection after it was sent may have been committed
+func (s *Store) retryWrite(ctx context.Context, fn func() error) error {
+	return s.retryWhile(ctx, notSent, fn)
+}
+
+//retryWhile run fn, retrying up to cfg.Retries times with backoff while retryable(err) and ctx is
+//not done
+func (s *Store) retryWhile(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	err := fn()
+	for attempt := 0; attempt < s.cfg.Retries && retryable(err); attempt++ {
+		select {
+		case <-time.After(s.cfg.backoff(attempt)):
+		case <-ctx.Done():
+			return err
+		}
+		err = fn()
+	}
+	return err
+}
+
+//Health status of the primary and the replicas, "ok" or the error
+type Health struct {
+	Primary  string            `json:"primary"`
+	Replicas map[string]string `json:"replicas,omitempty"`
+}
+
+//HealthCheck ping primary and replicas. Returns error when the primary is down, replicas that
+//are down are skipped for reads and replicas that are up are used again
+func HealthCheck(db *Store) (Health, error) {
+	return HealthCheckContext(context.Background(), db)
+}
+
+//HealthCheckContext HealthCheck with context, the connect timeout is used when ctx has no deadline
+func HealthCheckContext(ctx context.Context, db *Store) (Health, error) {
+	timeout := db.cfg.ConnectTimeout
+	if timeout <= 0 {
+		timeout = defaultPingTimeout
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+
+	ret := Health{Primary: "ok"}
+	err := db.db.PingContext(ctx)
+	if err != nil {
+		//driver errors can contain the dsn
+		err = redactError(err)
+		ret.Primary = err.Error()
+	}
+	if db.replicas != nil {
+		ret.Replicas = make(map[string]string)
+		for _, r := range db.replicas.list {
+			if e := r.db.PingContext(ctx); e != nil {
+				r.markDown()
+				ret.Replicas[r.addr] = Redact(e.Error())
+			} else {
+				r.markUp()
+				ret.Replicas[r.addr] = "ok"
+			}
+		}
+	}
+	return ret, err
+}
+
+//ServeHealth does HealthCheck and writes json to responseWriter, status 503 when the primary is down
+func ServeHealth(db *Store, w http.ResponseWriter) error {
+	health, err := HealthCheck(db)
+	bytes, e := json.Marshal(health)
+	if e != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return e
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(bytes)
+	return err
+}
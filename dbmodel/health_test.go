@@ -0,0 +1,85 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestRetry(t *testing.T) {
+	s := &Store{cfg: Config{Retries: 2, RetryBackoff: time.Millisecond, RetryMaxBackoff: time.Millisecond}}
+	lost := &mysql.MySQLError{Number: 2013, Message: "Lost connection to MySQL server during query"}
+	tests := []struct {
+		name  string
+		retry func(context.Context, func() error) error
+		err   error
+		calls int
+	}{
+		{"read lost connection", s.retry, lost, 3},
+		{"read bad connection", s.retry, driver.ErrBadConn, 3},
+		{"read syntax error", s.retry, &mysql.MySQLError{Number: 1064}, 1},
+		//a write that lost its connection may have been committed
+		{"write lost connection", s.retryWrite, lost, 1},
+		{"write invalid connection", s.retryWrite, mysql.ErrInvalidConn, 1},
+		{"write bad connection", s.retryWrite, driver.ErrBadConn, 3},
+		{"write dial", s.retryWrite, &net.OpError{Op: "dial", Err: errors.New("connection refused")}, 3},
+		{"write too many connections", s.retryWrite, &mysql.MySQLError{Number: 1040}, 3},
+	}
+	for _, tt := range tests {
+		calls := 0
+		err := tt.retry(context.Background(), func() error {
+			calls++
+			return tt.err
+		})
+		if calls != tt.calls || err != tt.err {
+			t.Errorf("%s: %d calls, want %d (%v)", tt.name, calls, tt.calls, err)
+		}
+	}
+
+	calls := 0
+	err := s.retryWrite(context.Background(), func() error {
+		if calls++; calls == 1 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Error("Expected success on retry, got", calls, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := Config{RetryBackoff: 100 * time.Millisecond, RetryMaxBackoff: time.Second}
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		if d := c.backoff(attempt); d < max/2 || d > max {
+			t.Errorf("backoff(%d) = %s, want between %s and %s", attempt, d, max/2, max)
+		}
+	}
+}
+
+func TestHealthCheckRedact(t *testing.T) {
+	keepSecrets(t)
+	registerSecret("pw-health")
+	connErr := errors.New("Could not connect as app:pw-health")
+	db := &Store{db: sql.OpenDB(testConnector{err: connErr}), replicas: &replicaSet{}}
+	db.replicas.list = []*replica{
+		{db: sql.OpenDB(testConnector{err: connErr}), addr: "down"},
+		{db: sql.OpenDB(testConnector{}), addr: "up"},
+	}
+	defer db.Close()
+	want := "Could not connect as app:" + redacted
+	health, err := HealthCheck(db)
+	if err == nil || err.Error() != want || !errors.Is(err, connErr) {
+		t.Error("Expected redacted error, got", err)
+	}
+	if health.Primary != want || health.Replicas["down"] != want || health.Replicas["up"] != "ok" {
+		t.Errorf("Expected redacted health, got %+v", health)
+	}
+}
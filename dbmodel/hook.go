@@ -0,0 +1,111 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+//QueryEvent statement run by dbmodel, passed to the hooks of the store
+type QueryEvent struct {
+	Query        string
+	Args         []interface{}
+	Start        time.Time
+	Duration     time.Duration //set for After, for reads including reading the rows
+	RowsAffected int64         //set for After, -1 for reads and when the driver does not know
+	Err          error         //set for After
+}
+
+//QueryHook called for every statement dbmodel runs on a store. Before can return a context
+//with values for After, e.g. a tracing span. Hooks must be safe for concurrent use
+type QueryHook interface {
+	Before(ctx context.Context, e *QueryEvent) context.Context
+	After(ctx context.Context, e *QueryEvent)
+}
+
+//AddHook register hook on s, hooks are called in the order they are added. Add hooks before
+//the store is used, stores returned by Primary share the hooks added before
+func (s *Store) AddHook(h QueryHook) {
+	s.hooks = append(s.hooks, h)
+}
+
+//before call Before of the hooks, event is nil when there are no hooks
+func (s *Store) before(ctx context.Context, query string, args []interface{}) (context.Context, *QueryEvent) {
+	if len(s.hooks) == 0 {
+		return ctx, nil
+	}
+	e := &QueryEvent{Query: query, Args: args, Start: time.Now(), RowsAffected: -1}
+	for _, h := range s.hooks {
+		ctx = h.Before(ctx, e)
+	}
+	return ctx, e
+}
+
+//after call After of the hooks with the result of the statement
+func (s *Store) after(ctx context.Context, e *QueryEvent, res sql.Result, err error) {
+	if e == nil {
+		return
+	}
+	e.Duration = time.Since(e.Start)
+	e.Err = err
+	if res != nil {
+		if n, err := res.RowsAffected(); err == nil {
+			e.RowsAffected = n
+		}
+	}
+	for _, h := range s.hooks {
+		h.After(ctx, e)
+	}
+}
+
+//LogHook logs every statement as key=value pairs, with secrets redacted
+type LogHook struct {
+	Logger *log.Logger //log.Default() when nil
+	Args   bool        //log arguments, they may contain personal data
+}
+
+//Before does nothing
+func (h LogHook) Before(ctx context.Context, e *QueryEvent) context.Context {
+	return ctx
+}
+
+//After log statement
+func (h LogHook) After(ctx context.Context, e *QueryEvent) {
+	logEvent(h.Logger, "query", e, h.Args)
+}
+
+//SlowQueryHook logs statements that take longer than Threshold
+type SlowQueryHook struct {
+	Threshold time.Duration
+	Logger    *log.Logger //log.Default() when nil
+	Args      bool        //log arguments, they may contain personal data
+}
+
+//Before does nothing
+func (h SlowQueryHook) Before(ctx context.Context, e *QueryEvent) context.Context {
+	return ctx
+}
+
+//After log statement when it was slow
+func (h SlowQueryHook) After(ctx context.Context, e *QueryEvent) {
+	if e.Duration >= h.Threshold {
+		logEvent(h.Logger, "slow_query", e, h.Args)
+	}
+}
+
+//logEvent write event to logger as key=value pairs
+func logEvent(logger *log.Logger, msg string, e *QueryEvent, args bool) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	line := fmt.Sprintf("msg=%s query=%q duration=%s rows_affected=%d", msg, e.Query, e.Duration, e.RowsAffected)
+	if args {
+		line += fmt.Sprintf(" args=%q", fmt.Sprintf("%v", e.Args))
+	}
+	if e.Err != nil {
+		line += fmt.Sprintf(" error=%q", e.Err.Error())
+	}
+	logger.Print(Redact(line))
+}
@@ -0,0 +1,96 @@
+package dbmodel
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ctxKey string
+
+//recordHook appends its name and the event to calls
+type recordHook struct {
+	name  string
+	calls *[]string
+	after []QueryEvent
+}
+
+func (h *recordHook) Before(ctx context.Context, e *QueryEvent) context.Context {
+	*h.calls = append(*h.calls, "before "+h.name)
+	return context.WithValue(ctx, ctxKey(h.name), true)
+}
+
+func (h *recordHook) After(ctx context.Context, e *QueryEvent) {
+	if ctx.Value(ctxKey(h.name)) != true {
+		*h.calls = append(*h.calls, "missing context of "+h.name)
+	}
+	*h.calls = append(*h.calls, "after "+h.name)
+	h.after = append(h.after, *e)
+}
+
+func TestHookOrder(t *testing.T) {
+	db := openSQLite(t, "create table h (id integer primary key, name text)")
+	var calls []string
+	first, second := &recordHook{name: "first", calls: &calls}, &recordHook{name: "second", calls: &calls}
+	db.AddHook(first)
+	db.AddHook(second)
+
+	if _, err := db.exec(context.Background(), "insert into h (name) values (?), (?)", "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"before first", "before second", "after first", "after second"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Got calls %v, want %v", calls, want)
+	}
+	e := second.after[0]
+	if e.Query != "insert into h (name) values (?), (?)" || len(e.Args) != 2 || e.RowsAffected != 2 || e.Err != nil || e.Start.IsZero() {
+		t.Errorf("Invalid event %+v", e)
+	}
+
+	calls = nil
+	if _, err := QueryContext(context.Background(), db, "select * from missing"); err == nil {
+		t.Fatal("Expected error")
+	}
+	if e = second.after[1]; e.Err == nil || e.RowsAffected != -1 {
+		t.Errorf("Expected error and -1 rows for a failed read, got %+v", e)
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Got calls %v, want %v", calls, want)
+	}
+}
+
+func TestSlowQueryHook(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	e := &QueryEvent{Query: "select * from t where id = ?", Args: []interface{}{7}, Duration: 20 * time.Millisecond, RowsAffected: -1}
+
+	SlowQueryHook{Threshold: 50 * time.Millisecond, Logger: logger}.After(context.Background(), e)
+	if buf.Len() != 0 {
+		t.Error("Expected nothing logged for a fast query, got", buf.String())
+	}
+	SlowQueryHook{Threshold: 10 * time.Millisecond, Logger: logger}.After(context.Background(), e)
+	want := `msg=slow_query query="select * from t where id = ?" duration=20ms rows_affected=-1` + "\n"
+	if buf.String() != want {
+		t.Errorf("Got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	SlowQueryHook{Logger: logger, Args: true}.After(context.Background(), e)
+	if !strings.Contains(buf.String(), `args="[7]"`) {
+		t.Error("Expected arguments, got", buf.String())
+	}
+
+	db := openSQLite(t)
+	buf.Reset()
+	db.AddHook(SlowQueryHook{Logger: logger})
+	if _, err := QueryContext(context.Background(), db, "select 1"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), `msg=slow_query query="select 1"`) {
+		t.Error("Expected every statement logged with threshold 0, got", buf.String())
+	}
+}
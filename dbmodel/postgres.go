@@ -0,0 +1,223 @@
+package dbmodel
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+func init() {
+	RegisterDialect(Postgres{})
+	registerErrorKind(postgresError)
+}
+
+//postgres error codes by kind of error
+var postgresErrors = map[pq.ErrorCode]error{
+	"23505": ErrDuplicateKey, //unique_violation
+	"23503": ErrForeignKey,   //foreign_key_violation
+	"22001": ErrDataTooLong,  //string_data_right_truncation
+	"40P01": ErrDeadlock,     //deadlock_detected
+	"55P03": ErrLockTimeout,  //lock_not_available
+}
+
+//postgresError returns err as *Error when it is a postgres error of a known kind
+func postgresError(err error) *Error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+	kind, ok := postgresErrors[pqErr.Code]
+	if !ok {
+		return nil
+	}
+	key := pqErr.Constraint
+	if kind == ErrDataTooLong {
+		key = pqErr.Column
+	}
+	return &Error{Kind: kind, Key: key, Err: err}
+}
+
+//Postgres dialect for PostgreSQL, dbmodel databases are postgres schemas
+type Postgres struct{}
+
+//Name returns dialect name
+func (Postgres) Name() string {
+	return "postgres"
+}
+
+//DriverName returns database/sql driver name
+func (Postgres) DriverName() string {
+	return "postgres"
+}
+
+//DefaultPort returns default server port
+func (Postgres) DefaultPort() int {
+	return 5432
+}
+
+//Validate check for missing server and user
+func (Postgres) Validate(c Config) error {
+	return validateServer(c)
+}
+
+//DSN returns key=value connection string for lib/pq
+func (Postgres) DSN(c Config) (string, error) {
+	var params []string
+	add := func(key, value string) {
+		if value != "" {
+			value = strings.Replace(value, "\\", "\\\\", -1)
+			value = strings.Replace(value, "'", "\\'", -1)
+			params = append(params, key+"='"+value+"'")
+		}
+	}
+	network, addr := c.address()
+	switch network {
+	case "unix":
+		//lib/pq connects to the .s.PGSQL.<port> socket in the host directory
+		if strings.HasPrefix(filepath.Base(addr), ".s.PGSQL.") {
+			add("port", strings.TrimPrefix(filepath.Base(addr), ".s.PGSQL."))
+			addr = filepath.Dir(addr)
+		} else {
+			add("port", strconv.Itoa(c.Port))
+		}
+		add("host", addr)
+	case "tcp":
+		add("host", c.Host)
+		add("port", strconv.Itoa(c.Port))
+	default:
+		return "", errors.New("Unsupported protocol for postgres: " + network)
+	}
+	add("user", c.User)
+	add("password", c.Password)
+	add("dbname", c.Database)
+	add("client_encoding", c.Charset)
+	if c.ConnectTimeout > 0 {
+		add("connect_timeout", strconv.Itoa(int((c.ConnectTimeout+999999999)/1000000000)))
+	}
+	switch c.tlsMode() {
+	case TLSDisable:
+		add("sslmode", "disable")
+	case TLSPreferred, TLSSkipVerify:
+		//lib/pq has no prefer mode, require does not verify the certificate
+		add("sslmode", "require")
+	case TLSVerifyCA, TLSVerifyFull:
+		add("sslmode", c.tlsMode())
+	default:
+		return "", errors.New("Invalid tls mode: " + c.TLSMode)
+	}
+	add("sslrootcert", c.TLSCA)
+	add("sslcert", c.TLSCert)
+	add("sslkey", c.TLSKey)
+	return strings.Join(params, " "), nil
+}
+
+//DatabasesQuery returns query for schema names, without system schemas
+func (Postgres) DatabasesQuery() string {
+	return "select schema_name from information_schema.schemata " +
+		"where schema_name not in ('information_schema', 'pg_catalog', 'pg_toast') and schema_name not like 'pg_temp_%' " +
+		"and schema_name not like 'pg_toast_temp_%' order by schema_name"
+}
+
+//TablesQuery returns query for table names in schema
+func (Postgres) TablesQuery(dbName string) (string, []interface{}) {
+	return "select table_name from information_schema.tables where table_schema = $1 order by table_name", []interface{}{dbName}
+}
+
+//ColumnsQuery returns query for columns in the same format as mysql show columns
+func (Postgres) ColumnsQuery(dbName, tblName string) (string, []interface{}) {
+	query := "select c.column_name, " +
+		"case when c.character_maximum_length is not null then c.data_type || '(' || c.character_maximum_length || ')' else c.data_type end, " +
+		"c.is_nullable, " +
+		"case when pk.column_name is not null then 'PRI' else '' end, " +
+		"c.column_default, " +
+		"case when c.column_default like 'nextval(%' or c.is_identity = 'YES' then 'auto_increment' else '' end " +
+		"from information_schema.columns c " +
+		"left join (select kcu.column_name from information_schema.table_constraints tc " +
+		"join information_schema.key_column_usage kcu on kcu.constraint_name = tc.constraint_name " +
+		"and kcu.table_schema = tc.table_schema and kcu.table_name = tc.table_name " +
+		"where tc.constraint_type = 'PRIMARY KEY' and tc.table_schema = $1 and tc.table_name = $2) pk " +
+		"on pk.column_name = c.column_name " +
+		"where c.table_schema = $1 and c.table_name = $2 order by c.ordinal_position"
+	return query, []interface{}{dbName, tblName}
+}
+
+//Quote quote identifier with double quotes
+func (Postgres) Quote(ident string) string {
+	return "\"" + strings.Replace(ident, "\"", "\"\"", -1) + "\""
+}
+
+//Placeholder returns $n
+func (Postgres) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+//Escape string for standard conforming strings, backslashes are not special
+func (Postgres) Escape(str string) string {
+	str = strings.Replace(str, "''", "'", -1)
+	str = strings.Replace(str, "'", "''", -1)
+	str = strings.Replace(str, "\x00", "", -1)
+	str = strings.Replace(str, ";", " ", -1)
+	str = strings.Replace(str, "--", "", -1)
+	str = strings.Replace(str, "/*", "", -1)
+	str = strings.Replace(str, "*/", "", -1)
+	return str
+}
+
+//Upsert returns insert ... on conflict do update statement, plain insert for tables without keys
+func (p Postgres) Upsert(table string, fields []string, keys []string, values []interface{}) (string, []interface{}) {
+	return onConflictUpsert(p, table, fields, keys), values
+}
+
+//upsertRows returns insert ... values (...), (...) on conflict do update statement
+func (p Postgres) upsertRows(table string, fields []string, keys []string, rows int) string {
+	return onConflictUpsertRows(p, table, fields, keys, rows)
+}
+
+//maxArgs returns max parameters of a statement
+func (Postgres) maxArgs() int {
+	return 65535
+}
+
+//maxPacketQuery returns empty string, there is no limit that matters
+func (Postgres) maxPacketQuery() string {
+	return ""
+}
+
+//onConflictUpsert returns insert ... on conflict (keys) do update statement, used by postgres and sqlite
+func onConflictUpsert(d Dialect, table string, fields []string, keys []string) string {
+	return onConflictUpsertRows(d, table, fields, keys, 1)
+}
+
+//onConflictUpsertRows returns onConflictUpsert statement that inserts rows rows
+func onConflictUpsertRows(d Dialect, table string, fields []string, keys []string, rows int) string {
+	quoted := make([]string, len(fields))
+	update := make([]string, len(fields))
+	for i, f := range fields {
+		quoted[i] = d.Quote(f)
+		update[i] = d.Quote(f) + " = excluded." + d.Quote(f)
+	}
+	values := make([]string, rows)
+	for i := range values {
+		values[i] = "(" + placeholders(d, i*len(fields)+1, len(fields)) + ")"
+	}
+	query := "insert into " + table + " (" + strings.Join(quoted, ", ") + ") values " + strings.Join(values, ", ")
+	if len(keys) > 0 {
+		quotedKeys := make([]string, len(keys))
+		for i, k := range keys {
+			quotedKeys[i] = d.Quote(k)
+		}
+		query += " on conflict (" + strings.Join(quotedKeys, ", ") + ") do update set " + strings.Join(update, ", ")
+	}
+	return query
+}
+
+//Returning returns returning clause for the auto increment column
+func (p Postgres) Returning(field string) string {
+	if field == "" {
+		return ""
+	}
+	return " returning " + p.Quote(field)
+}
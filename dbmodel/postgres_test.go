@@ -0,0 +1,73 @@
+package dbmodel
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostgresDSN(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{Host: "db", Port: 5432, User: "app", Password: "it's a \\secret", Database: "shop"},
+			`host='db' port='5432' user='app' password='it\'s a \\secret' dbname='shop' sslmode='disable'`},
+		{Config{Socket: "/run/postgresql/.s.PGSQL.5433", User: "app"},
+			`port='5433' host='/run/postgresql' user='app' sslmode='disable'`},
+		{Config{Host: "/run/postgresql", Port: 5432, User: "app"},
+			`port='5432' host='/run/postgresql' user='app' sslmode='disable'`},
+		{Config{Host: "db", Port: 5432, Charset: "UTF8", ConnectTimeout: 1500 * time.Millisecond},
+			`host='db' port='5432' client_encoding='UTF8' connect_timeout='2' sslmode='disable'`},
+		{Config{Host: "db", Port: 5432, TLSMode: TLSPreferred},
+			`host='db' port='5432' sslmode='require'`},
+		{Config{Host: "db", Port: 5432, TLSCA: "ca.pem", TLSCert: "c.pem", TLSKey: "k.pem"},
+			`host='db' port='5432' sslmode='verify-full' sslrootcert='ca.pem' sslcert='c.pem' sslkey='k.pem'`},
+	}
+	for _, tt := range tests {
+		if got, err := (Postgres{}).DSN(tt.cfg); got != tt.want || err != nil {
+			t.Errorf("DSN(%+v):\n got %s %v\nwant %s", tt.cfg, got, err, tt.want)
+		}
+	}
+	for _, cfg := range []Config{{Host: "db", Protocol: "udp"}, {Host: "db", TLSMode: "sometimes"}} {
+		if _, err := (Postgres{}).DSN(cfg); err == nil {
+			t.Errorf("Expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestPostgresColumnsQuery(t *testing.T) {
+	query, args := Postgres{}.ColumnsQuery("public", "orders")
+	if !reflect.DeepEqual(args, []interface{}{"public", "orders"}) {
+		t.Error("Expected schema and table as arguments, got", args)
+	}
+	for _, part := range []string{
+		"select c.column_name, ",
+		"tc.constraint_type = 'PRIMARY KEY' and tc.table_schema = $1 and tc.table_name = $2",
+		"where c.table_schema = $1 and c.table_name = $2 order by c.ordinal_position",
+	} {
+		if !strings.Contains(query, part) {
+			t.Errorf("Expected %q in %s", part, query)
+		}
+	}
+	if strings.Contains(query, "public") || strings.Contains(query, "orders") {
+		t.Error("Expected names as arguments only, got", query)
+	}
+}
+
+func TestPostgresUpsert(t *testing.T) {
+	values := []interface{}{1, "a"}
+	query, args := Postgres{}.Upsert(`"public"."t"`, []string{"id", "name"}, []string{"id"}, values)
+	want := `insert into "public"."t" ("id", "name") values ($1, $2) on conflict ("id") do update set "id" = excluded."id", "name" = excluded."name"`
+	if query != want || !reflect.DeepEqual(args, values) {
+		t.Errorf("Upsert:\n got %s %v\nwant %s", query, args, want)
+	}
+	query, _ = Postgres{}.Upsert(`"public"."log"`, []string{"msg"}, nil, []interface{}{"x"})
+	if want = `insert into "public"."log" ("msg") values ($1)`; query != want {
+		t.Errorf("Upsert without keys:\n got %s\nwant %s", query, want)
+	}
+	if got := (Postgres{}).Returning("id"); got != ` returning "id"` {
+		t.Error("Expected returning clause, got", got)
+	}
+}
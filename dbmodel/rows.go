@@ -0,0 +1,256 @@
+package dbmodel
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+//Row row of a result with values typed like QueryTyped, in select order. Rows of QueryEach are
+//reused for the next row, only use them inside the callback or copy them with Copy or Map
+type Row struct {
+	columns []string
+	types   []string
+	values  []interface{}
+}
+
+//Rows result of QueryRows, keeps the select order of the columns
+type Rows struct {
+	columns []string
+	types   []string
+	rows    [][]interface{}
+}
+
+//QueryRows run query and return the rows with the columns in select order, values are typed like
+//QueryTyped. Columns with the same name, e.g. from a join, are all kept
+func QueryRows(db *Store, query string, args ...interface{}) (*Rows, error) {
+	return QueryRowsContext(context.Background(), db, query, args...)
+}
+
+//QueryRowsContext QueryRows with context, the query is stopped when ctx is done
+func QueryRowsContext(ctx context.Context, db *Store, query string, args ...interface{}) (*Rows, error) {
+	res := &Rows{rows: make([][]interface{}, 0)}
+	err := queryEach(ctx, db, query, args, func(columns, types []string) error {
+		res.columns, res.types = columns, types
+		return nil
+	}, func(row Row) error {
+		res.rows = append(res.rows, row.Values())
+		return nil
+	})
+	return res, err
+}
+
+//Columns returns column names in select order
+func (r Row) Columns() []string {
+	return r.columns
+}
+
+//Types returns database type names of the columns, e.g. VARCHAR or INT
+func (r Row) Types() []string {
+	return r.types
+}
+
+//Len returns number of columns
+func (r Row) Len() int {
+	return len(r.values)
+}
+
+//Value returns value of column i (0-based), nil when the value is NULL
+func (r Row) Value(i int) interface{} {
+	return r.values[i]
+}
+
+//Values returns copy of the values in select order
+func (r Row) Values() []interface{} {
+	return append([]interface{}{}, r.values...)
+}
+
+//Index returns index of the first column named col, -1 when there is none
+func (r Row) Index(col string) int {
+	for i, c := range r.columns {
+		if c == col {
+			return i
+		}
+	}
+	return -1
+}
+
+//Get returns value of the first column named col, nil when the value is NULL or the column does not exist
+func (r Row) Get(col string) interface{} {
+	if i := r.Index(col); i >= 0 {
+		return r.values[i]
+	}
+	return nil
+}
+
+//Copy returns copy of row that is not reused
+func (r Row) Copy() Row {
+	return Row{columns: r.columns, types: r.types, values: r.Values()}
+}
+
+//Map returns copy of row as map, see Keys for columns with the same name
+func (r Row) Map() map[string]interface{} {
+	m := make(map[string]interface{}, len(r.columns))
+	for i, k := range r.Keys() {
+		m[k] = r.values[i]
+	}
+	return m
+}
+
+//Keys returns column names as map and json keys. Of columns with the same name the first keeps
+//it, the next get a suffix: id, id_2, id_3
+func (r Row) Keys() []string {
+	keys := make([]string, len(r.columns))
+	used := make(map[string]bool, len(r.columns))
+	for _, c := range r.columns {
+		used[c] = true
+	}
+	seen := make(map[string]bool, len(r.columns))
+	for i, c := range r.columns {
+		keys[i] = c
+		if seen[c] {
+			for n := 2; used[keys[i]]; n++ {
+				keys[i] = c + "_" + strconv.Itoa(n)
+			}
+			used[keys[i]] = true
+		}
+		seen[c] = true
+	}
+	return keys
+}
+
+//MarshalJSON returns row as json object with the keys in select order, see Keys for columns with
+//the same name
+func (r Row) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, c := range r.Keys() {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+//Columns returns column names in select order
+func (r *Rows) Columns() []string {
+	return r.columns
+}
+
+//Types returns database type names of the columns, e.g. VARCHAR or INT
+func (r *Rows) Types() []string {
+	return r.types
+}
+
+//Len returns number of rows
+func (r *Rows) Len() int {
+	return len(r.rows)
+}
+
+//Row returns row i (0-based)
+func (r *Rows) Row(i int) Row {
+	return Row{columns: r.columns, types: r.types, values: r.rows[i]}
+}
+
+//Value returns value of column col (0-based) in row i, nil when the value is NULL
+func (r *Rows) Value(i, col int) interface{} {
+	return r.rows[i][col]
+}
+
+//Get returns value of the first column named col in row i
+func (r *Rows) Get(i int, col string) interface{} {
+	return r.Row(i).Get(col)
+}
+
+//Maps returns rows as maps, like QueryTyped
+func (r *Rows) Maps() []map[string]interface{} {
+	ret := make([]map[string]interface{}, len(r.rows))
+	for i := range r.rows {
+		ret[i] = r.Row(i).Map()
+	}
+	return ret
+}
+
+//MarshalJSON returns rows as json array of objects with the keys in select order
+func (r *Rows) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i := range r.rows {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		row, err := r.Row(i).MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		b.Write(row)
+	}
+	b.WriteByte(']')
+	return b.Bytes(), nil
+}
+
+//WriteCSV write rows as csv with a header line, values are formatted with ToString
+func (r *Rows) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(r.columns); err != nil {
+		return err
+	}
+	record := make([]string, len(r.columns))
+	for _, row := range r.rows {
+		for i, v := range row {
+			record[i] = ToString(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//WriteTable write rows as text table with aligned columns, NULL is written as NULL
+func (r *Rows) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	//tabs and line breaks in values would break the alignment
+	clean := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+	writeLine := func(values []string) {
+		for i, v := range values {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, clean.Replace(v))
+		}
+		fmt.Fprint(tw, "\n")
+	}
+	writeLine(r.columns)
+	line := make([]string, len(r.columns))
+	for _, row := range r.rows {
+		for i, v := range row {
+			if v == nil {
+				line[i] = "NULL"
+			} else {
+				line[i] = ToString(v)
+			}
+		}
+		writeLine(line)
+	}
+	return tw.Flush()
+}
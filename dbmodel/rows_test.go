@@ -0,0 +1,89 @@
+package dbmodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func openRows(t *testing.T) *Rows {
+	db := openSQLite(t,
+		"create table r (zid integer primary key, b varchar(20), a int)",
+		"create table r2 (zid integer primary key, b varchar(20))",
+		"insert into r values (1, 'x\ty', 3), (2, null, 4)",
+		"insert into r2 values (1, 'other')",
+	)
+	rows, err := QueryRows(db, "select r.zid, r.b, r.a, r2.b from r left join r2 on r.zid = r2.zid order by r.zid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestRows(t *testing.T) {
+	rows := openRows(t)
+	if want := []string{"zid", "b", "a", "b"}; !reflect.DeepEqual(rows.Columns(), want) {
+		t.Error("Expected columns in select order, got", rows.Columns())
+	}
+	if want := []string{"INTEGER", "varchar(20)", "INT", "varchar(20)"}; !reflect.DeepEqual(rows.Types(), want) {
+		t.Error("Expected types, got", rows.Types())
+	}
+	if rows.Len() != 2 || rows.Value(0, 3) != "other" || rows.Get(0, "b") != "x\ty" || rows.Value(1, 1) != nil || rows.Get(1, "missing") != nil {
+		t.Error("Invalid values", rows.Row(0).Values(), rows.Row(1).Values())
+	}
+	row := rows.Row(0)
+	if row.Len() != 4 || row.Index("a") != 2 || row.Index("missing") != -1 || row.Value(0) != int64(1) {
+		t.Error("Invalid row", row.Values())
+	}
+	values := row.Values()
+	values[0] = nil
+	if rows.Value(0, 0) != int64(1) {
+		t.Error("Expected Values to return a copy")
+	}
+	if maps := rows.Maps(); len(maps) != 2 || maps[0]["b"] != "x\ty" || maps[0]["b_2"] != "other" || maps[1]["zid"] != int64(2) {
+		t.Error("Invalid maps", maps)
+	}
+
+	b, err := json.Marshal(rows)
+	if want := `[{"zid":1,"b":"x\ty","a":3,"b_2":"other"},{"zid":2,"b":null,"a":4,"b_2":null}]`; err != nil || string(b) != want {
+		t.Errorf("Got json %s %v, want %s", b, err, want)
+	}
+
+	empty, err := QueryRows(openSQLite(t, "create table e (id int, name text)"), "select * from e")
+	if err != nil || empty.Len() != 0 || !reflect.DeepEqual(empty.Columns(), []string{"id", "name"}) {
+		t.Error("Expected columns without rows, got", empty, err)
+	}
+	if b, _ = json.Marshal(empty); string(b) != "[]" {
+		t.Error("Expected empty json array, got", string(b))
+	}
+}
+
+func TestRowKeys(t *testing.T) {
+	row := Row{columns: []string{"id", "id", "id_2", "name", "id"}}
+	if want := []string{"id", "id_3", "id_2", "name", "id_4"}; !reflect.DeepEqual(row.Keys(), want) {
+		t.Error("Expected unique keys, got", row.Keys())
+	}
+}
+
+func TestRowsWrite(t *testing.T) {
+	rows := openRows(t)
+	var buf bytes.Buffer
+	if err := rows.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "zid,b,a,b\n1,x\ty,3,other\n2,,4,\n"; buf.String() != want {
+		t.Errorf("Got csv %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := rows.WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "zid  b     a  b\n" +
+		"1    x y   3  other\n" +
+		"2    NULL  4  NULL\n"
+	if buf.String() != want {
+		t.Errorf("Got table\n%s\nwant\n%s", buf.String(), want)
+	}
+}
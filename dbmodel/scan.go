@@ -0,0 +1,263 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+//fieldCache column name to field index by struct type
+var fieldCache sync.Map
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+//Fetch run query and scan rows into slice of T, T is a struct or a pointer to a struct. Columns
+//are mapped to fields with a db:"column" tag or by field name, case insensitive and with
+//CreatedAt matching created_at. Fields of embedded structs are included, db:"-" skips a field.
+//NULL sets a field to its zero value, pointer fields are set to nil. Columns without field are skipped
+func Fetch[T any](ctx context.Context, db *Store, query string, args ...interface{}) ([]T, error) {
+	ret := make([]T, 0)
+	err := fetch(ctx, db, query, args, func(v reflect.Value) bool {
+		ret = append(ret, v.Interface().(T))
+		return true
+	}, reflect.TypeOf((*T)(nil)).Elem())
+	return ret, err
+}
+
+//Get run query and scan the first row into T like Fetch, returns ErrNotFound when there is no row,
+//errors.Is(err, sql.ErrNoRows) is true as well
+func Get[T any](ctx context.Context, db *Store, query string, args ...interface{}) (T, error) {
+	var ret T
+	found := false
+	err := fetch(ctx, db, query, args, func(v reflect.Value) bool {
+		ret = v.Interface().(T)
+		found = true
+		return false
+	}, reflect.TypeOf((*T)(nil)).Elem())
+	if err == nil && !found {
+		err = notFound(sql.ErrNoRows)
+	}
+	return ret, err
+}
+
+//fetch scan rows into new values of type t and call fn for each, stops when fn returns false
+func fetch(ctx context.Context, db *Store, query string, args []interface{}, fn func(reflect.Value) bool, t reflect.Type) error {
+	structType := t
+	if t.Kind() == reflect.Ptr {
+		structType = t.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return errors.New("Cannot scan into " + t.String() + ", not a struct")
+	}
+	fields := structFields(structType)
+
+	rows, done, err := db.query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer done()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	index := make([][]int, len(columns))
+	for i, c := range columns {
+		index[i] = fields[strings.ToLower(c)]
+	}
+	dest := make([]interface{}, len(columns))
+	for rows.Next() {
+		ptr := reflect.New(structType)
+		var after []func()
+		for i, idx := range index {
+			if idx == nil {
+				dest[i] = new(sql.RawBytes)
+				continue
+			}
+			field := fieldByIndex(ptr.Elem(), idx)
+			if !field.IsValid() {
+				dest[i] = new(sql.RawBytes)
+				continue
+			}
+			var set func()
+			dest[i], set = fieldDest(field)
+			if set != nil {
+				after = append(after, set)
+			}
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		for _, set := range after {
+			set()
+		}
+		v := ptr
+		if t.Kind() != reflect.Ptr {
+			v = ptr.Elem()
+		}
+		if !fn(v) {
+			return nil
+		}
+	}
+	return rows.Err()
+}
+
+//fieldDest returns scan destination for field, set copies the scanned value into the field
+func fieldDest(field reflect.Value) (dest interface{}, set func()) {
+	addr := field.Addr()
+	switch {
+	case addr.Type().Implements(scannerType):
+		return addr.Interface(), nil
+	case field.Type() == timeType || (field.Kind() == reflect.Ptr && field.Type().Elem() == timeType):
+		return &timeField{field: field}, nil
+	case field.Kind() == reflect.Ptr:
+		//database/sql sets the pointer to nil for NULL
+		return addr.Interface(), nil
+	}
+	//scan into pointer to field type so NULL becomes the zero value
+	p := reflect.New(reflect.PtrTo(field.Type()))
+	return p.Interface(), func() {
+		if !p.Elem().IsNil() {
+			field.Set(p.Elem().Elem())
+		}
+	}
+}
+
+//timeField scans time.Time and date text (mysql without parseTime) into a time.Time or *time.Time field
+type timeField struct {
+	field reflect.Value
+}
+
+func (f *timeField) Scan(src interface{}) error {
+	var t time.Time
+	switch v := src.(type) {
+	case nil:
+		f.field.Set(reflect.Zero(f.field.Type()))
+		return nil
+	case time.Time:
+		t = v
+	case []byte, string:
+		parsed, ok := converter("DATETIME")(toBytes(v)).(time.Time)
+		if !ok {
+			return errors.New("Cannot convert " + string(toBytes(v)) + " to time")
+		}
+		t = parsed
+	default:
+		return errors.New("Cannot convert " + reflect.TypeOf(src).String() + " to time")
+	}
+	if f.field.Kind() == reflect.Ptr {
+		f.field.Set(reflect.ValueOf(&t))
+	} else {
+		f.field.Set(reflect.ValueOf(t))
+	}
+	return nil
+}
+
+//toBytes returns []byte or string v as []byte
+func toBytes(v interface{}) []byte {
+	if s, ok := v.(string); ok {
+		return []byte(s)
+	}
+	return v.([]byte)
+}
+
+//fieldByIndex like reflect.Value.FieldByIndex, nil embedded struct pointers are allocated.
+//Returns the zero Value when a nil pointer can not be set
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+//structFields returns field index by lower case column name for struct type t, cached per type
+func structFields(t reflect.Type) map[string][]int {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+	fields := make(map[string][]int)
+	tagged := make(map[string]bool)
+	//structs being walked, a struct that embeds a pointer to itself is walked once
+	walking := map[reflect.Type]bool{t: true}
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			//tag without name like db:",opt" uses the field name
+			tag := strings.Split(f.Tag.Get("db"), ",")[0]
+			if tag == "-" {
+				continue
+			}
+			idx := append(append([]int{}, index...), i)
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if f.Anonymous && tag == "" && ft.Kind() == reflect.Struct && ft != timeType {
+				if f.PkgPath != "" && f.Type.Kind() == reflect.Ptr {
+					//pointer to unexported struct, can not be allocated
+					continue
+				}
+				if !walking[ft] {
+					walking[ft] = true
+					walk(ft, idx)
+					delete(walking, ft)
+				}
+				continue
+			}
+			if f.PkgPath != "" {
+				//unexported
+				continue
+			}
+			names := []string{strings.ToLower(f.Name), snakeCase(f.Name)}
+			if tag != "" {
+				names = []string{strings.ToLower(tag)}
+			}
+			for _, name := range names {
+				//tags win over names, fields of the outer struct win over embedded fields
+				if old, exists := fields[name]; exists {
+					if tagged[name] && tag == "" || tagged[name] == (tag != "") && len(old) <= len(idx) {
+						continue
+					}
+				}
+				fields[name] = idx
+				tagged[name] = tag != ""
+			}
+		}
+	}
+	walk(t, nil)
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+//snakeCase returns CreatedAt as created_at and ID as id
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
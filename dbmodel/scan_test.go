@@ -0,0 +1,157 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Audit struct {
+	ID        int64
+	CreatedAt time.Time
+}
+
+type Note struct {
+	Note *string
+}
+
+type order struct {
+	Audit
+	*Note
+	Name    string `db:"title"`
+	Qty     int
+	Price   sql.NullFloat64
+	Shipped *time.Time `db:"shipped_at"`
+	Ignored string     `db:"-"`
+	hidden  int
+}
+
+func openOrders(t *testing.T) *Store {
+	return openSQLite(t,
+		"create table o (id integer primary key, created_at datetime, title text, qty int, price real, shipped_at datetime, note text, ignored text, hidden int, extra text)",
+		"insert into o values (1, '2024-01-02 03:04:05', 'a', 3, 1.5, null, 'n', 'x', 1, 'e')",
+		"insert into o values (2, null, null, null, null, '2024-05-06', null, null, null, null)",
+	)
+}
+
+func TestFetch(t *testing.T) {
+	ctx := context.Background()
+	db := openOrders(t)
+	res, err := Fetch[order](ctx, db, "select * from o order by id")
+	if err != nil || len(res) != 2 {
+		t.Fatal(res, err)
+	}
+	note := "n"
+	want := order{
+		Audit: Audit{ID: 1, CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		Note:  &Note{Note: &note},
+		Name:  "a",
+		Qty:   3,
+		Price: sql.NullFloat64{Float64: 1.5, Valid: true},
+	}
+	if !reflect.DeepEqual(res[0], want) {
+		t.Errorf("Got %+v, want %+v", res[0], want)
+	}
+	//NULL is the zero value, nil for pointers
+	shipped := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	if got := res[1]; got.ID != 2 || !got.CreatedAt.IsZero() || got.Name != "" || got.Qty != 0 || got.Price.Valid ||
+		got.Note == nil || got.Note.Note != nil || got.Shipped == nil || !got.Shipped.Equal(shipped) {
+		t.Errorf("Invalid row with NULLs %+v", got)
+	}
+
+	ptrs, err := Fetch[*order](ctx, db, "select id, title from o where id > ?", 1)
+	if err != nil || len(ptrs) != 1 || ptrs[0].ID != 2 || ptrs[0].Note != nil {
+		t.Error("Expected pointer to the second order, got", ptrs, err)
+	}
+	if none, err := Fetch[order](ctx, db, "select * from o where id = 9"); err != nil || none == nil || len(none) != 0 {
+		t.Error("Expected empty slice, got", none, err)
+	}
+	if _, err = Fetch[int](ctx, db, "select 1"); err == nil {
+		t.Error("Expected error for int")
+	}
+}
+
+func TestGet(t *testing.T) {
+	ctx := context.Background()
+	db := openOrders(t)
+	o, err := Get[order](ctx, db, "select * from o where title = ?", "a")
+	if err != nil || o.ID != 1 || o.Qty != 3 {
+		t.Error("Expected first order, got", o, err)
+	}
+	p, err := Get[*order](ctx, db, "select * from o order by id desc")
+	if err != nil || p.ID != 2 {
+		t.Error("Expected the first row of the result, got", p, err)
+	}
+	_, err = Get[order](ctx, db, "select * from o where id = 9")
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, sql.ErrNoRows) {
+		t.Error("Expected ErrNotFound, got", err)
+	}
+	if p, err = Get[*order](ctx, db, "select * from o where id = 9"); p != nil || err == nil {
+		t.Error("Expected nil and an error, got", p, err)
+	}
+}
+
+func TestStructFields(t *testing.T) {
+	type base struct {
+		ID   int
+		Name string
+	}
+	type tags struct {
+		base
+		Name     string
+		UserID   int
+		HTTPPort int
+		Title    string `db:"name"`
+		Other    string `db:"Code,omitempty"`
+		Opt      string `db:",opt"`
+	}
+	type node struct {
+		*node
+		Value int
+	}
+	type list struct {
+		ID int
+		node
+	}
+	tests := []struct {
+		t    reflect.Type
+		want map[string][]int
+	}{
+		{reflect.TypeOf(tags{}), map[string][]int{
+			"id": {0, 0}, "name": {4}, "userid": {2}, "user_id": {2},
+			"httpport": {3}, "http_port": {3}, "title": nil, "code": {5}, "other": nil, "opt": {6}, "": nil,
+		}},
+		//a struct that embeds a pointer to itself is walked once
+		{reflect.TypeOf(node{}), map[string][]int{"value": {1}}},
+		{reflect.TypeOf(list{}), map[string][]int{"id": {0}, "value": {1, 1}}},
+	}
+	for _, tt := range tests {
+		fields := structFields(tt.t)
+		for name, want := range tt.want {
+			if got := fields[name]; !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: field %s = %v, want %v", tt.t, name, got, want)
+			}
+		}
+	}
+}
+
+type unexported struct {
+	Name string
+}
+
+func TestFetchEmbeddedPointer(t *testing.T) {
+	//a nil pointer to an unexported struct can not be allocated, its fields are skipped
+	type row struct {
+		ID int
+		*unexported
+		*Note
+	}
+	db := openSQLite(t)
+	res, err := Fetch[row](context.Background(), db, "select 1 as id, 'a' as name, 'n' as note")
+	if err != nil || len(res) != 1 || res[0].ID != 1 || res[0].unexported != nil || res[0].Note == nil || *res[0].Note.Note != "n" {
+		t.Error("Expected id and note, got", res, err)
+	}
+}
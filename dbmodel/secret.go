@@ -0,0 +1,131 @@
+package dbmodel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//defaultCommandTimeout max run time of password_command
+const defaultCommandTimeout = 10 * time.Second
+
+//redacted replaces secrets in log lines and error messages
+const redacted = "******"
+
+var (
+	secrets     []string //longest first, so a secret that contains another is replaced whole
+	secretMutex sync.RWMutex
+)
+
+//registerSecret redact s from log lines and error messages
+func registerSecret(s string) {
+	if s == "" {
+		return
+	}
+	secretMutex.Lock()
+	defer secretMutex.Unlock()
+	for _, secret := range secrets {
+		if secret == s {
+			return
+		}
+	}
+	secrets = append(secrets, s)
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+}
+
+//Redact replace known secrets in str
+func Redact(str string) string {
+	secretMutex.RLock()
+	defer secretMutex.RUnlock()
+	for _, s := range secrets {
+		str = strings.Replace(str, s, redacted, -1)
+	}
+	return str
+}
+
+//redactedError error with secrets removed from the message, Unwrap returns the original error
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+//redactError returns err with secrets removed from the message
+func redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := Redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{err: err, msg: msg}
+}
+
+//logPrintln log.Println with secrets removed
+func logPrintln(v ...interface{}) {
+	log.Print(Redact(fmt.Sprintln(v...)))
+}
+
+//resolvePassword returns copy of c with the password read from PasswordEnv, PasswordFile or
+//PasswordCommand when Password is empty. The password is registered as secret
+func (c Config) resolvePassword() (Config, error) {
+	var err error
+	switch {
+	case c.Password != "":
+	case c.PasswordEnv != "":
+		c.Password = os.Getenv(c.PasswordEnv)
+		if c.Password == "" {
+			return c, errors.New("Environment variable " + c.PasswordEnv + " for password is empty")
+		}
+	case c.PasswordFile != "":
+		var b []byte
+		if b, err = os.ReadFile(c.PasswordFile); err != nil {
+			return c, errors.New("Could not read password file: " + err.Error())
+		}
+		c.Password = strings.TrimRight(string(b), "\r\n")
+	case c.PasswordCommand != "":
+		if c.Password, err = runPasswordCommand(c.PasswordCommand, c.ConnectTimeout); err != nil {
+			return c, err
+		}
+	}
+	registerSecret(c.Password)
+	return c, nil
+}
+
+//runPasswordCommand run command with sh and return the first line of its output
+func runPasswordCommand(command string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.New("Password command failed: " + err.Error() + ": " + strings.TrimSpace(stderr.String()))
+	}
+	password := strings.SplitN(stdout.String(), "\n", 2)[0]
+	password = strings.TrimRight(password, "\r")
+	if password == "" {
+		return "", errors.New("Password command returned no password")
+	}
+	return password, nil
+}
@@ -0,0 +1,78 @@
+package dbmodel
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//keepSecrets restore the registered secrets after the test
+func keepSecrets(t *testing.T) {
+	secretMutex.Lock()
+	saved := append([]string{}, secrets...)
+	secretMutex.Unlock()
+	t.Cleanup(func() {
+		secretMutex.Lock()
+		secrets = saved
+		secretMutex.Unlock()
+	})
+}
+
+func TestRedact(t *testing.T) {
+	keepSecrets(t)
+	registerSecret("")
+	registerSecret("pw")
+	registerSecret("pw-long-secret")
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Access denied, password pw-long-secret", "Access denied, password " + redacted},
+		{"short pw", "short " + redacted},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	err := errors.New("Could not connect with pw")
+	if got := redactError(err); got.Error() != "Could not connect with "+redacted || !errors.Is(got, err) {
+		t.Error("Expected redacted error that wraps err, got", got)
+	}
+}
+
+func TestResolvePassword(t *testing.T) {
+	keepSecrets(t)
+	file := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(file, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_ORM_PASSWORD", "env-secret")
+	tests := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{Password: "plain-secret", PasswordEnv: "TEST_ORM_PASSWORD"}, "plain-secret"},
+		{Config{PasswordEnv: "TEST_ORM_PASSWORD"}, "env-secret"},
+		{Config{PasswordFile: file}, "file-secret"},
+		{Config{PasswordCommand: "echo cmd-secret; echo second line"}, "cmd-secret"},
+	}
+	for _, tt := range tests {
+		cfg, err := tt.cfg.resolvePassword()
+		if err != nil || cfg.Password != tt.want {
+			t.Errorf("Expected %s, got %s %v", tt.want, cfg.Password, err)
+		}
+		if strings.Contains(Redact("password "+tt.want), tt.want) {
+			t.Error("Password not redacted:", tt.want)
+		}
+	}
+	if _, err := (Config{PasswordEnv: "TEST_ORM_EMPTY"}).resolvePassword(); err == nil {
+		t.Error("Expected error for empty environment variable")
+	}
+	if _, err := (Config{PasswordCommand: "exit 1"}).resolvePassword(); err == nil {
+		t.Error("Expected error for failing command")
+	}
+}
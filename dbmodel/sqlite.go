@@ -0,0 +1,163 @@
+package dbmodel
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	RegisterDialect(SQLite{})
+	registerErrorKind(sqliteError)
+}
+
+//sqlite extended error codes by kind of error
+var sqliteErrors = map[sqlite3.ErrNoExtended]error{
+	sqlite3.ErrConstraintUnique:     ErrDuplicateKey,
+	sqlite3.ErrConstraintPrimaryKey: ErrDuplicateKey,
+	sqlite3.ErrConstraintForeignKey: ErrForeignKey,
+}
+
+//columns of the key named in sqlite messages
+var errorSqliteKeyReg = regexp.MustCompile("constraint failed: (.*)$")
+
+//sqliteError returns err as *Error when it is a sqlite error of a known kind
+func sqliteError(err error) *Error {
+	var liteErr sqlite3.Error
+	if !errors.As(err, &liteErr) {
+		return nil
+	}
+	if kind, ok := sqliteErrors[liteErr.ExtendedCode]; ok {
+		key := ""
+		if m := errorSqliteKeyReg.FindStringSubmatch(liteErr.Error()); m != nil {
+			key = m[1]
+		}
+		return &Error{Kind: kind, Key: key, Err: err}
+	}
+	if liteErr.Code == sqlite3.ErrBusy || liteErr.Code == sqlite3.ErrLocked {
+		return &Error{Kind: ErrLockTimeout, Err: err}
+	}
+	return nil
+}
+
+//SQLite dialect for SQLite files and in-memory databases, dbmodel databases are
+//sqlite schemas (main, temp or attached databases)
+type SQLite struct{}
+
+//Name returns dialect name
+func (SQLite) Name() string {
+	return "sqlite"
+}
+
+//DriverName returns database/sql driver name
+func (SQLite) DriverName() string {
+	return "sqlite3"
+}
+
+//DefaultPort returns 0, sqlite has no server
+func (SQLite) DefaultPort() int {
+	return 0
+}
+
+//Validate nothing is required, without Database an in-memory database is used
+func (SQLite) Validate(c Config) error {
+	return nil
+}
+
+//DSN returns file name with driver parameters
+func (SQLite) DSN(c Config) (string, error) {
+	dsn := c.Database
+	if isMemory(c) {
+		dsn = ":memory:"
+	}
+	var params []string
+	if c.ConnectTimeout > 0 {
+		params = append(params, "_busy_timeout="+strconv.FormatInt(c.ConnectTimeout.Milliseconds(), 10))
+	}
+	if len(params) > 0 {
+		dsn += "?" + strings.Join(params, "&")
+	}
+	return dsn, nil
+}
+
+//poolConfig an in-memory database lives as long as its connection, keep one connection open
+func (SQLite) poolConfig(c Config) Config {
+	if isMemory(c) {
+		c.MaxOpenConns = 1
+		c.MaxIdleConns = 1
+		c.ConnMaxLifetime = -1
+	}
+	return c
+}
+
+//isMemory true when c is an in-memory sqlite database
+func isMemory(c Config) bool {
+	return c.Database == "" || c.Database == ":memory:"
+}
+
+//DatabasesQuery returns query for schema names
+func (SQLite) DatabasesQuery() string {
+	return "select name from pragma_database_list order by seq"
+}
+
+//TablesQuery returns query for table names in schema
+func (s SQLite) TablesQuery(dbName string) (string, []interface{}) {
+	return "select name from " + s.Quote(dbName) + ".sqlite_master " +
+		"where type = 'table' and name not like 'sqlite_%' order by name", nil
+}
+
+//ColumnsQuery returns query for columns in the same format as mysql show columns, an integer
+//primary key is an alias for rowid and is reported as auto_increment
+func (SQLite) ColumnsQuery(dbName, tblName string) (string, []interface{}) {
+	query := "select name, type, " +
+		"case when \"notnull\" = 0 then 'YES' else 'NO' end, " +
+		"case when pk > 0 then 'PRI' else '' end, " +
+		"dflt_value, " +
+		"case when pk = 1 and upper(type) = 'INTEGER' and (select count(*) from pragma_table_info(?, ?) where pk > 0) = 1 " +
+		"then 'auto_increment' else '' end " +
+		"from pragma_table_info(?, ?) order by cid"
+	return query, []interface{}{tblName, dbName, tblName, dbName}
+}
+
+//Quote quote identifier with double quotes
+func (SQLite) Quote(ident string) string {
+	return "\"" + strings.Replace(ident, "\"", "\"\"", -1) + "\""
+}
+
+//Placeholder returns ?
+func (SQLite) Placeholder(n int) string {
+	return "?"
+}
+
+//Escape string, backslashes are not special
+func (SQLite) Escape(str string) string {
+	return Postgres{}.Escape(str)
+}
+
+//Upsert returns insert ... on conflict do update statement, plain insert for tables without keys
+func (s SQLite) Upsert(table string, fields []string, keys []string, values []interface{}) (string, []interface{}) {
+	return onConflictUpsert(s, table, fields, keys), values
+}
+
+//upsertRows returns insert ... values (...), (...) on conflict do update statement
+func (s SQLite) upsertRows(table string, fields []string, keys []string, rows int) string {
+	return onConflictUpsertRows(s, table, fields, keys, rows)
+}
+
+//maxArgs returns SQLITE_MAX_VARIABLE_NUMBER of sqlite 3.32 and later
+func (SQLite) maxArgs() int {
+	return 32766
+}
+
+//maxPacketQuery returns empty string, sqlite has no server
+func (SQLite) maxPacketQuery() string {
+	return ""
+}
+
+//Returning returns empty string, sqlite has LastInsertId
+func (SQLite) Returning(field string) string {
+	return ""
+}
@@ -0,0 +1,126 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"testing"
+)
+
+//openSQLite returns in-memory sqlite store with the tables of schema
+func openSQLite(t *testing.T, schema ...string) *Store {
+	t.Helper()
+	db, err := Open(Config{Dialect: "sqlite"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range schema {
+		if _, err := db.DB().Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+//withValues returns copy of cols with values set by field
+func withValues(cols []Column, values map[string]interface{}) []Column {
+	ret := append([]Column{}, cols...)
+	for i, c := range ret {
+		if v, ok := values[c.Field]; ok {
+			ret[i].Value = v
+		}
+	}
+	return ret
+}
+
+func TestSQLiteSchema(t *testing.T) {
+	db := openSQLite(t, "create table orders (id integer primary key, name varchar(20) not null, qty int default 1)")
+	if names := GetDatabaseNames(db); len(names) == 0 || names[0] != "main" {
+		t.Error("Expected database main, got", names)
+	}
+	if tables := GetTableNames(db, "main"); len(tables) != 1 || tables[0] != "orders" {
+		t.Error("Expected table orders, got", tables)
+	}
+	cols := GetColumns(db, "main", "orders")
+	if len(cols) != 3 {
+		t.Fatal("Expected 3 columns, got", cols)
+	}
+	if cols[0].Field != "id" || cols[0].Key != "PRI" || GetType(cols[0].Type) != "int" {
+		t.Error("Invalid key column", cols[0])
+	}
+	if cols[1].Null != "NO" || cols[2].Default != "1" {
+		t.Error("Invalid columns", cols[1:])
+	}
+}
+
+func TestSQLiteSaveDelete(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t, "create table orders (id integer primary key, name varchar(20) not null)")
+	cols := GetColumns(db, "main", "orders")
+
+	n, id, err := save(ctx, db, "main", "orders", withValues(cols, map[string]interface{}{"name": "a"}))
+	if err != nil || n != 1 || id != 1 {
+		t.Fatal("Insert:", n, id, err)
+	}
+	if _, _, err = save(ctx, db, "main", "orders", withValues(cols, map[string]interface{}{"id": 1, "name": "b"})); err != nil {
+		t.Fatal("Update:", err)
+	}
+	rows, err := Query(db, "select * from orders")
+	if err != nil || len(rows) != 1 || rows[0]["name"] != "b" {
+		t.Fatal("Expected updated row, got", rows, err)
+	}
+	if _, err = deleteRow(ctx, db, "main", "orders", withValues(cols, map[string]interface{}{"id": 1})); err != nil {
+		t.Fatal("Delete:", err)
+	}
+	if _, err = deleteRow(ctx, db, "main", "orders", withValues(cols, map[string]interface{}{"id": 1})); err == nil {
+		t.Error("Expected error for delete of a missing row")
+	}
+}
+
+func TestSQLiteReplicas(t *testing.T) {
+	//a store with sqlite stores as replicas, each with its own name in table who
+	db := openSQLite(t, "create table who (name text)", "insert into who values ('primary')")
+	rs := &replicaSet{policy: RoundRobin}
+	for _, name := range []string{"r1", "r2"} {
+		r := openSQLite(t, "create table who (name text)", "insert into who values ('"+name+"')")
+		rs.list = append(rs.list, &replica{db: r.DB(), addr: name})
+	}
+	db.replicas = rs
+	who := func(s *Store) interface{} {
+		t.Helper()
+		rows, err := Query(s, "select name from who")
+		if err != nil || len(rows) != 1 {
+			t.Fatal(rows, err)
+		}
+		return rows[0]["name"]
+	}
+
+	if a, b := who(db), who(db); a != "r2" || b != "r1" {
+		t.Error("Expected reads on the replicas in turn, got", a, b)
+	}
+	if got := who(db.Primary()); got != "primary" {
+		t.Error("Expected read on the primary, got", got)
+	}
+	if _, err := db.exec(context.Background(), "update who set name = 'written'"); err != nil {
+		t.Fatal(err)
+	}
+	if got := who(db.Primary()); got != "written" {
+		t.Error("Expected the write on the primary, got", got)
+	}
+
+	//a replica that can not connect is marked down and the read falls back to the primary
+	down := &replica{db: sql.OpenDB(testConnector{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}), addr: "down"}
+	t.Cleanup(func() { down.db.Close() })
+	rs.list = []*replica{down}
+	if got := who(db); got != "written" {
+		t.Error("Expected fallback to the primary, got", got)
+	}
+	if down.healthy() {
+		t.Error("Expected replica to be marked down")
+	}
+	if rs.pick() != nil {
+		t.Error("Expected no healthy replica")
+	}
+}
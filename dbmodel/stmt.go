@@ -0,0 +1,221 @@
+package dbmodel
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+//default number of prepared statements kept per store
+const defaultStmtCacheSize = 100
+
+//preparedKey context key that marks a statement to be prepared
+type preparedKey struct{}
+
+//stmtKey prepared statement by connection pool and query. The queries of save, delete and
+//primary key lookups only depend on the table and the set of columns
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+type stmtEntry struct {
+	key     stmtKey
+	stmt    *sql.Stmt
+	refs    int  //statements running, the statement is closed when it is dropped and refs is 0
+	dropped bool //removed from the cache
+}
+
+//stmtCache LRU cache of prepared statements. database/sql prepares a statement on each
+//connection of the pool it runs on, and again on new connections after a connection is reset
+type stmtCache struct {
+	mutex   sync.Mutex
+	max     int
+	lru     *list.List //*stmtEntry, most recently used first
+	entries map[stmtKey]*list.Element
+}
+
+//preparedConn runs statements on a connection pool as cached prepared statements
+type preparedConn struct {
+	cache *stmtCache
+	db    *sql.DB
+}
+
+//newStmtCache returns cache for max statements, nil when max is negative
+func newStmtCache(max int) *stmtCache {
+	if max < 0 {
+		return nil
+	}
+	if max == 0 {
+		max = defaultStmtCacheSize
+	}
+	return &stmtCache{max: max, lru: list.New(), entries: make(map[stmtKey]*list.Element)}
+}
+
+//Prepared returns ctx that marks statements run with it to be prepared and cached. Used for the
+//statements of save, delete and primary key lookups, that are run often with other values
+func Prepared(ctx context.Context) context.Context {
+	return context.WithValue(ctx, preparedKey{}, true)
+}
+
+//isPrepared true when ctx was returned by Prepared
+func isPrepared(ctx context.Context) bool {
+	p, _ := ctx.Value(preparedKey{}).(bool)
+	return p
+}
+
+//conn returns pool db or the transaction of s for one statement. Statements marked with Prepared
+//use a cached prepared statement when they run on the pool
+func (s *Store) conn(ctx context.Context, db *sql.DB) sqlConn {
+	if s.tx != nil {
+		//statements prepared on a transaction stay open until it ends, a long transaction
+		//would collect them
+		return s.tx.tx
+	}
+	if s.stmts != nil && isPrepared(ctx) {
+		return &preparedConn{cache: s.stmts, db: db}
+	}
+	return db
+}
+
+//ExecContext exec prepared statement for query, prepared again when the server lost it
+func (c *preparedConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var res sql.Result
+	err := c.run(ctx, query, func(stmt *sql.Stmt) error {
+		var err error
+		res, err = stmt.ExecContext(ctx, args...)
+		return err
+	})
+	return res, err
+}
+
+//QueryContext run prepared statement for query, prepared again when the server lost it
+func (c *preparedConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := c.run(ctx, query, func(stmt *sql.Stmt) error {
+		var err error
+		rows, err = stmt.QueryContext(ctx, args...)
+		return err
+	})
+	return rows, err
+}
+
+//run call fn with the prepared statement for query. When the statement is no longer known on the
+//server (connection reset, schema change) it is prepared again and fn is called once more
+func (c *preparedConn) run(ctx context.Context, query string, fn func(*sql.Stmt) error) error {
+	key := stmtKey{db: c.db, query: query}
+	for attempt := 0; ; attempt++ {
+		e, err := c.cache.acquire(ctx, key)
+		if err != nil {
+			return err
+		}
+		err = fn(e.stmt)
+		if err != nil && attempt == 0 && isStmtLost(err) {
+			c.cache.drop(e)
+			c.cache.release(e)
+			continue
+		}
+		c.cache.release(e)
+		return err
+	}
+}
+
+//acquire returns statement for key, prepared when it is not cached. Call release when done
+func (c *stmtCache) acquire(ctx context.Context, key stmtKey) (*stmtEntry, error) {
+	c.mutex.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+		e := el.Value.(*stmtEntry)
+		e.refs++
+		c.mutex.Unlock()
+		return e, nil
+	}
+	c.mutex.Unlock()
+
+	stmt, err := key.db.PrepareContext(ctx, key.query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if el, ok := c.entries[key]; ok {
+		//prepared at the same time by another statement
+		stmt.Close()
+		c.lru.MoveToFront(el)
+		e := el.Value.(*stmtEntry)
+		e.refs++
+		return e, nil
+	}
+	e := &stmtEntry{key: key, stmt: stmt, refs: 1}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.max {
+		c.remove(c.lru.Back().Value.(*stmtEntry))
+	}
+	return e, nil
+}
+
+//release statement returned by acquire
+func (c *stmtCache) release(e *stmtEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	e.refs--
+	if e.dropped && e.refs == 0 {
+		e.stmt.Close()
+	}
+}
+
+//drop remove statement from the cache, so the next acquire prepares it again
+func (c *stmtCache) drop(e *stmtEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.remove(e)
+}
+
+//close drop all statements, they are closed when they are no longer running
+func (c *stmtCache) close() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for c.lru.Len() > 0 {
+		c.remove(c.lru.Front().Value.(*stmtEntry))
+	}
+}
+
+//remove entry, closed when it is not running. Caller holds the mutex
+func (c *stmtCache) remove(e *stmtEntry) {
+	if e.dropped {
+		return
+	}
+	e.dropped = true
+	if el, ok := c.entries[e.key]; ok && el.Value == e {
+		c.lru.Remove(el)
+		delete(c.entries, e.key)
+	}
+	if e.refs == 0 {
+		e.stmt.Close()
+	}
+}
+
+//isStmtLost true when err means the prepared statement no longer exists on the server, or no
+//longer matches the table
+func isStmtLost(err error) bool {
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1243 //unknown prepared statement handler
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "26000" || //prepared statement does not exist
+			pqErr.Code == "0A000" && strings.Contains(pqErr.Message, "cached plan")
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package dbmodel
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+//killSQLite sqlite dialect that kills statements like mysql, the kill is recorded
+type killSQLite struct {
+	SQLite
+}
+
+var (
+	killedID    int64
+	killedMutex sync.Mutex
+)
+
+func (killSQLite) Name() string {
+	return "killsqlite"
+}
+
+func (killSQLite) connectionIDQuery() string {
+	return "select 7"
+}
+
+func (killSQLite) killQuery(id int64) string {
+	killedMutex.Lock()
+	defer killedMutex.Unlock()
+	killedID = id
+	return "select 1"
+}
+
+func openKillSQLite(t *testing.T, cfg Config) *Store {
+	RegisterDialect(killSQLite{})
+	cfg.Dialect = "killsqlite"
+	cfg.Database = filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPreparedWithTimeout(t *testing.T) {
+	db := openKillSQLite(t, Config{QueryTimeout: time.Minute, ExecTimeout: time.Minute})
+	if _, err := db.exec(context.Background(), "create table t (id integer primary key, name text)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.exec(Prepared(context.Background()), "insert into t values (?, ?)", 1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	query := "select name from t where id = ?"
+	var stmt interface{}
+	for i := 0; i < 2; i++ {
+		var name string
+		if err := db.queryRow(Prepared(context.Background()), query, []interface{}{1}, &name); err != nil || name != "a" {
+			t.Fatal(name, err)
+		}
+		el, ok := db.stmts.entries[stmtKey{db: db.db, query: query}]
+		if !ok {
+			t.Fatal("Statement not cached")
+		}
+		if i == 1 && el.Value.(*stmtEntry).stmt != stmt {
+			t.Error("Statement prepared again")
+		}
+		stmt = el.Value.(*stmtEntry).stmt
+	}
+}
+
+func TestKillOnTimeout(t *testing.T) {
+	db := openKillSQLite(t, Config{ExecTimeout: 50 * time.Millisecond})
+	_, err := db.exec(context.Background(), "with recursive c(x) as (select 1 union all select x + 1 from c) select count(*) from c")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected deadline exceeded, got", err)
+	}
+	killedMutex.Lock()
+	defer killedMutex.Unlock()
+	if killedID != 7 {
+		t.Error("Expected kill of connection 7, got", killedID)
+	}
+}
+
+func TestKillFullPool(t *testing.T) {
+	//the only connection of the pool runs the statement, the kill can not wait for it
+	db := openKillSQLite(t, Config{ExecTimeout: 50 * time.Millisecond, MaxOpenConns: 1})
+	killedMutex.Lock()
+	killedID = 0
+	killedMutex.Unlock()
+	start := time.Now()
+	_, err := db.exec(context.Background(), "with recursive c(x) as (select 1 union all select x + 1 from c) select count(*) from c")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected deadline exceeded, got", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Error("Expected the statement to return without waiting for the pool, took", d)
+	}
+	killedMutex.Lock()
+	defer killedMutex.Unlock()
+	if killedID != 7 {
+		t.Error("Expected kill of connection 7, got", killedID)
+	}
+}
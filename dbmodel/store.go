@@ -0,0 +1,191 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+//Store long-lived database handle, owns one connection pool that is shared by all operations
+type Store struct {
+	db       *sql.DB //primary, used for writes
+	cfg      Config
+	dialect  Dialect
+	replicas *replicaSet
+	primary  bool //read from primary
+	cache    *queryCache
+	stmts    *stmtCache //prepared statements, nil when disabled
+	tx       *Tx        //transaction the statements run in
+	hooks    []QueryHook
+}
+
+var (
+	defaultStore *Store
+	defaultMutex sync.Mutex
+)
+
+//Open create store with connection pool for cfg
+func Open(cfg Config) (*Store, error) {
+	db, err := ConnectConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	d, err := GetDialect(cfg.Dialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	replicas, err := openReplicas(cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	cfg = cfg.withDefaults()
+	return &Store{db: db, cfg: cfg, dialect: d, replicas: replicas, stmts: newStmtCache(cfg.StmtCacheSize)}, nil
+}
+
+//Primary returns store that sends reads to the primary, sharing the connection pools of s
+func (s *Store) Primary() *Store {
+	p := *s
+	p.primary = true
+	return &p
+}
+
+//reader returns connection pool for reads, a healthy replica when available
+func (s *Store) reader() (*sql.DB, *replica) {
+	if !s.primary {
+		if r := s.replicas.pick(); r != nil {
+			return r.db, r
+		}
+	}
+	return s.db, nil
+}
+
+//query run read query on a replica, falls back to the primary when the replica connection fails.
+//Retried on transient errors. QueryTimeout is used when ctx has no deadline. Call done when
+//finished with the rows, it closes them
+func (s *Store) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, func(), error) {
+	ctx, cancel := withTimeout(ctx, s.cfg.QueryTimeout)
+	ctx, e := s.before(ctx, query, args)
+	var rows *sql.Rows
+	run := func(db *sql.DB) error {
+		var err error
+		rows, err = s.conn(ctx, db).QueryContext(ctx, query, args...)
+		return err
+	}
+	err := s.retry(ctx, func() error {
+		db, r := s.reader()
+		err := run(db)
+		if r != nil && isConnError(err) {
+			r.markDown()
+			err = run(s.db)
+		}
+		return err
+	})
+	if err != nil {
+		err = dbError(err)
+		s.after(ctx, e, nil, err)
+		cancel()
+		return nil, nil, err
+	}
+	done := func() {
+		err := rows.Err()
+		rows.Close()
+		s.after(ctx, e, nil, dbError(err))
+		cancel()
+	}
+	return rows, done, nil
+}
+
+//queryRow run query that returns one row on the primary and scan it into dest, used for
+//statements that write. ExecTimeout is used when ctx has no deadline
+func (s *Store) queryRow(ctx context.Context, query string, args []interface{}, dest ...interface{}) error {
+	ctx, cancel := withTimeout(ctx, s.cfg.ExecTimeout)
+	defer cancel()
+	ctx, e := s.before(ctx, query, args)
+	rows, err := s.conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err == nil {
+		err = scanRow(rows, dest...)
+	}
+	err = dbError(err)
+	s.after(ctx, e, nil, err)
+	return err
+}
+
+//scanRow scan first row into dest and close rows, sql.ErrNoRows when there is no row
+func scanRow(rows *sql.Rows, dest ...interface{}) error {
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+//exec run statement on the primary. ExecTimeout is used when ctx has no deadline
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, s.cfg.ExecTimeout)
+	defer cancel()
+	ctx, e := s.before(ctx, query, args)
+	res, err := s.conn(ctx, s.db).ExecContext(ctx, query, args...)
+	err = dbError(err)
+	s.after(ctx, e, res, err)
+	return res, err
+}
+
+//DB returns the primary connection pool of the store
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+//Dialect returns the sql dialect of the store
+func (s *Store) Dialect() Dialect {
+	return s.dialect
+}
+
+//Config returns the configuration the store was opened with
+func (s *Store) Config() Config {
+	return s.cfg
+}
+
+//Close close the connection pools
+func (s *Store) Close() error {
+	s.stmts.close()
+	err := s.replicas.close()
+	if e := s.db.Close(); e != nil {
+		err = e
+	}
+	return err
+}
+
+//Default returns the shared store, opened with LoadConfig on first use.
+//Used by Save, Delete, DoQuery, ServeQuery, HandleREST and the generated code
+func Default() (*Store, error) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	if defaultStore != nil {
+		return defaultStore, nil
+	}
+	cfg, err := LoadConfig(Config{})
+	if err != nil {
+		return nil, err
+	}
+	s, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defaultStore = s
+	return defaultStore, nil
+}
+
+//SetDefault set the shared store returned by Default
+func SetDefault(s *Store) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	defaultStore = s
+}
@@ -0,0 +1,105 @@
+package dbmodel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+//setDefault use db as default store until the end of the test
+func setDefault(t *testing.T, db *Store) {
+	defaultMutex.Lock()
+	old := defaultStore
+	defaultMutex.Unlock()
+	SetDefault(db)
+	t.Cleanup(func() { SetDefault(old) })
+}
+
+func TestQueryEach(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t, "create table s (id integer primary key, name text)", "insert into s values (1, 'a'), (2, 'b'), (3, null)")
+	var rows []map[string]interface{}
+	var names []interface{}
+	var columns []string
+	err := QueryEach(ctx, db, "select id, name from s where id > ? order by id", []interface{}{1}, func(row Row) error {
+		rows = append(rows, row.Map())
+		names = append(names, row.Get("name"))
+		columns = row.Columns()
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(names, []interface{}{"b", nil}) {
+		t.Error("Expected names of rows 2 and 3, got", names, err)
+	}
+	if len(rows) != 2 || rows[0]["id"] != int64(2) || !reflect.DeepEqual(columns, []string{"id", "name"}) {
+		t.Error("Expected copies of the rows, got", rows, columns)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = QueryEach(ctx, db, "select * from s", nil, func(row Row) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Error("Expected stop after the first row, got", calls, err)
+	}
+	if err = QueryEach(ctx, db, "select * from missing", nil, func(Row) error { return nil }); err == nil {
+		t.Error("Expected error for missing table")
+	}
+}
+
+func TestStreamJSON(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t, "create table s (id integer primary key, name text)")
+	tests := []struct {
+		rows   int
+		unwrap bool
+		want   string
+	}{
+		{0, false, `[]`},
+		{1, false, `[{"id":1,"name":"n"}]`},
+		{3, false, `[{"id":1,"name":"n"},{"id":2,"name":"n"},{"id":3,"name":"n"}]`},
+		{0, true, `[]`},
+		{1, true, `{"id":1,"name":"n"}`},
+		{2, true, `[{"id":1,"name":"n"},{"id":2,"name":"n"}]`},
+	}
+	for _, tt := range tests {
+		db.DB().Exec("delete from s")
+		for i := 1; i <= tt.rows; i++ {
+			db.DB().Exec("insert into s values (?, 'n')", i)
+		}
+		w := httptest.NewRecorder()
+		if err := streamJSON(ctx, db, w, tt.unwrap, nil, "select * from s order by id"); err != nil {
+			t.Fatal(err)
+		}
+		if w.Body.String() != tt.want || w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("%d rows, unwrap %v: got %s, want %s", tt.rows, tt.unwrap, w.Body.String(), tt.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	upper := func(b []byte) []byte { return bytes.ToUpper(b) }
+	if err := streamJSON(ctx, db, w, false, upper, "select * from s where id = ?", 2); err != nil || w.Body.String() != `[{"ID":2,"NAME":"N"}]` {
+		t.Error("Expected filtered row, got", w.Body.String(), err)
+	}
+	w = httptest.NewRecorder()
+	if err := streamJSON(ctx, db, w, false, nil, "select * from missing"); err == nil || w.Body.Len() != 0 {
+		t.Error("Expected error before writing, got", w.Body.String(), err)
+	}
+}
+
+func TestServeQuery(t *testing.T) {
+	db := openSQLite(t, "create table s (id integer primary key, name text)", "insert into s values (1, 'a'), (2, 'b')")
+	setDefault(t, db)
+	w := httptest.NewRecorder()
+	if err := ServeQuery("select name from s where id > ?", w, 0); err != nil || w.Body.String() != `[{"name":"a"},{"name":"b"}]` {
+		t.Error("Expected both rows, got", w.Body.String(), err)
+	}
+	w = httptest.NewRecorder()
+	if err := ServeQuery("select * from missing", w); err == nil || w.Code != 500 {
+		t.Error("Expected internal server error, got", w.Code, err)
+	}
+}
@@ -0,0 +1,203 @@
+package dbmodel
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//default rows per page and max limit of a REST GET on a table
+const (
+	DefaultPageSize    = 100
+	DefaultMaxPageSize = 1000
+)
+
+//PasswordColumns password columns, hidden from REST results by default
+var PasswordColumns = regexp.MustCompile("(?i)^(passwo?r?d|wachtwo?o?r?d?)$")
+
+//TableQuery reads the url query of a REST GET on a table: column=value filters (repeat for in),
+//order=col,-col, limit, offset, after=key and q=where clause. Used by rest.Handler and HandleREST.
+//A where clause in q can read any column or table with a subquery, only allow it for trusted clients.
+//A q that names a hidden column is refused to catch mistakes, that does not hide the column
+type TableQuery struct {
+	RawWhere      bool           //allow where clauses in q, for trusted clients only: it gives full sql read access
+	HiddenColumns *regexp.Regexp //columns that can not be used in filters, order and q, PasswordColumns when nil
+	PageSize      int            //rows without limit, DefaultPageSize when 0
+	MaxPageSize   int            //max limit, DefaultMaxPageSize when 0
+}
+
+//Page rows of a REST GET on a table. Without order the rows are sorted on the primary key and
+//the next page starts after the last key, with order or offset the next page is read with an offset
+type Page struct {
+	Limit  int
+	Offset int
+	After  []string //primary key of the last row of the previous page
+	Keys   []string //primary key columns
+	Keyset bool     //rows are sorted on the primary key, the next page starts after the last key
+}
+
+//Select returns select of table dbName.tblName with the filters and order of params, and the page
+//of params. Apply the page to the select to read it. The error is meant for the client
+func (q TableQuery) Select(dbName, tblName string, d Dialect, cols []Column, params url.Values) (*SelectBuilder, *Page, error) {
+	hidden := q.HiddenColumns
+	if hidden == nil {
+		hidden = PasswordColumns
+	}
+	sel := Select().From(dbName, tblName)
+	if where, ok := params["q"]; ok {
+		if !q.RawWhere {
+			return nil, nil, errors.New("Parameter q is not allowed")
+		}
+		for _, c := range cols {
+			if hidden.MatchString(c.Field) && mentions(where[0], c.Field) {
+				return nil, nil, errors.New("Parameter q names hidden column: " + c.Field)
+			}
+		}
+		//parenthesized, an or in q can not escape the filters and the page
+		sel.Where(Expr{SQL: "(" + strings.Replace(d.Escape(where[0]), "''", "'", -1) + ")"})
+	}
+	eq := Eq{}
+	for _, c := range cols {
+		if values, ok := params[c.Field]; ok {
+			if hidden.MatchString(c.Field) {
+				return nil, nil, errors.New("Invalid column: " + c.Field)
+			}
+			if len(values) == 1 {
+				eq[c.Field] = values[0]
+			} else {
+				eq[c.Field] = values
+			}
+		}
+	}
+	if len(eq) > 0 {
+		sel.Where(eq)
+	}
+	if order := params.Get("order"); order != "" {
+		for _, o := range strings.Split(order, ",") {
+			o = strings.TrimSpace(o)
+			field := strings.TrimLeft(o, "+-")
+			if findColIndex(field, cols) == -1 || hidden.MatchString(field) {
+				return nil, nil, errors.New("Invalid order column: " + o)
+			}
+			sel.OrderBy(o)
+		}
+	}
+	p, err := q.page(cols, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sel, p, nil
+}
+
+//page returns page of params
+func (q TableQuery) page(cols []Column, params url.Values) (*Page, error) {
+	p := &Page{Limit: q.PageSize}
+	if p.Limit <= 0 {
+		p.Limit = DefaultPageSize
+	}
+	max := q.MaxPageSize
+	if max <= 0 {
+		max = DefaultMaxPageSize
+	}
+	for _, c := range cols {
+		if c.Key == "PRI" {
+			p.Keys = append(p.Keys, c.Field)
+		}
+	}
+	var err error
+	if limit := params.Get("limit"); limit != "" {
+		if p.Limit, err = strconv.Atoi(limit); err != nil || p.Limit < 1 {
+			return nil, errors.New("Invalid limit: " + limit)
+		}
+	}
+	if p.Limit > max {
+		p.Limit = max
+	}
+	if offset := params.Get("offset"); offset != "" {
+		if p.Offset, err = strconv.Atoi(offset); err != nil || p.Offset < 0 {
+			return nil, errors.New("Invalid offset: " + offset)
+		}
+	}
+	sorted := params.Get("order") != ""
+	if after, ok := params["after"]; ok {
+		switch {
+		case sorted || p.Offset > 0:
+			return nil, errors.New("Parameter after can not be used with order or offset")
+		case len(p.Keys) == 0:
+			return nil, errors.New("Parameter after needs a primary key")
+		}
+		p.After = strings.Split(after[0], ":")
+		if len(p.After) != len(p.Keys) {
+			return nil, errors.New("Invalid after: " + after[0])
+		}
+	}
+	p.Keyset = len(p.Keys) > 0 && !sorted && p.Offset == 0
+	return p, nil
+}
+
+//Apply add sort, limit and offset of the page to sel
+func (p *Page) Apply(sel *SelectBuilder) {
+	if p.Keyset {
+		sel.OrderBy(p.Keys...)
+	}
+	if p.After != nil {
+		//(k1 > v1) or (k1 = v1 and k2 > v2) or ...
+		var or Or
+		for i := range p.Keys {
+			and := And{}
+			for j := 0; j < i; j++ {
+				and = append(and, Eq{p.Keys[j]: p.After[j]})
+			}
+			or = append(or, append(and, Gt{p.Keys[i]: p.After[i]}))
+		}
+		sel.Where(or)
+	}
+	sel.Limit(p.Limit).Offset(p.Offset)
+}
+
+//Next returns url query of the next page, last is the last row of this page
+func (p *Page) Next(params url.Values, last Row) url.Values {
+	next := copyValues(params)
+	next.Set("limit", strconv.Itoa(p.Limit))
+	if p.Keyset {
+		key := make([]string, len(p.Keys))
+		for i, k := range p.Keys {
+			key[i] = ToString(last.Get(k))
+		}
+		next.Set("after", strings.Join(key, ":"))
+	} else {
+		next.Set("offset", strconv.Itoa(p.Offset+p.Limit))
+	}
+	return next
+}
+
+//Prev returns url query of the previous page, nil on the first page or when rows are read
+//after a key
+func (p *Page) Prev(params url.Values) url.Values {
+	if p.Keyset || p.Offset == 0 {
+		return nil
+	}
+	prev := copyValues(params)
+	prev.Set("limit", strconv.Itoa(p.Limit))
+	if offset := p.Offset - p.Limit; offset > 0 {
+		prev.Set("offset", strconv.Itoa(offset))
+	} else {
+		prev.Del("offset")
+	}
+	return prev
+}
+
+//mentions true when where clause q contains the word col, case insensitive
+func mentions(q string, col string) bool {
+	return regexp.MustCompile(`(?i)(^|[^\w$])` + regexp.QuoteMeta(col) + `([^\w$]|$)`).MatchString(q)
+}
+
+func copyValues(params url.Values) url.Values {
+	c := make(url.Values, len(params))
+	for k, v := range params {
+		c[k] = append([]string{}, v...)
+	}
+	return c
+}
@@ -0,0 +1,83 @@
+package dbmodel
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestTableQuery(t *testing.T) {
+	cols := []Column{{Field: "id", Key: "PRI"}, {Field: "name"}, {Field: "password"}}
+	tests := []struct {
+		query string
+		sql   string
+		err   string
+	}{
+		{"", `select * from "main"."t" order by "id" limit 100`, ""},
+		{"name=a&limit=5000", `select * from "main"."t" where "name" = ? order by "id" limit 1000`, ""},
+		{"after=7&limit=2", `select * from "main"."t" where "id" > ? order by "id" limit 2`, ""},
+		{"order=-name&offset=10", `select * from "main"."t" order by "name" desc limit 100 offset 10`, ""},
+		{"q=id<3", `select * from "main"."t" where (id<3) order by "id" limit 100`, ""},
+		{"q=name='a' or name='b'&id=5&after=10", `select * from "main"."t" where (name='a' or name='b') and "id" = ? and "id" > ? order by "id" limit 100`, ""},
+		{"password=x", "", "Invalid column: password"},
+		{"order=password", "", "Invalid order column: password"},
+		{"q=password='x'", "", "Parameter q names hidden column: password"},
+		{"limit=x", "", "Invalid limit: x"},
+		{"after=1&offset=2", "", "Parameter after can not be used with order or offset"},
+	}
+	for _, tt := range tests {
+		params, _ := url.ParseQuery(tt.query)
+		sel, page, err := TableQuery{RawWhere: true}.Select("main", "t", SQLite{}, cols, params)
+		if err != nil {
+			if err.Error() != tt.err {
+				t.Errorf("%s: got error %v, want %s", tt.query, err, tt.err)
+			}
+			continue
+		}
+		page.Apply(sel)
+		if query, _ := sel.ToSQL(SQLite{}); query != tt.sql || tt.err != "" {
+			t.Errorf("%s:\n got %s\nwant %s %s", tt.query, query, tt.sql, tt.err)
+		}
+	}
+	if _, _, err := (TableQuery{}).Select("main", "t", SQLite{}, cols, url.Values{"q": {"1=1"}}); err == nil {
+		t.Error("Expected q to be refused without RawWhere")
+	}
+}
+
+func TestHandleRESTPage(t *testing.T) {
+	db := openSQLite(t, "create table lp (id integer primary key, name text)")
+	for i := 1; i <= 150; i++ {
+		db.DB().Exec("insert into lp values (?, 'x')", i)
+	}
+	setDefault(t, db)
+	tests := []struct {
+		target   string
+		status   int
+		first, n int
+	}{
+		{"/api/main/lp", 200, 1, 150},
+		{"/api/main/lp?after=100", 200, 101, 50},
+		{"/api/main/lp?limit=5000&offset=140", 200, 141, 10},
+		{"/api/main/lp?q=id<3&order=-id", 200, 2, 2},
+		{"/api/main/lp?limit=-1", 400, 0, 0},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		HandleREST("api", w, httptest.NewRequest("GET", tt.target, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: got %d %s, want %d", tt.target, w.Code, w.Body.String(), tt.status)
+			continue
+		}
+		if tt.status != 200 {
+			continue
+		}
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+			t.Fatal(w.Body.String(), err)
+		}
+		if len(rows) != tt.n || ToString(rows[0]["id"]) != ToString(tt.first) {
+			t.Errorf("%s: got %d rows from %v, want %d from %d", tt.target, len(rows), rows[0]["id"], tt.n, tt.first)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package dbmodel
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//tls verify modes
+const (
+	TLSDisable    = "disable"     //plain connection
+	TLSPreferred  = "preferred"   //tls when the server supports it, not verified
+	TLSSkipVerify = "skip-verify" //tls, certificate is not verified
+	TLSVerifyCA   = "verify-ca"   //tls, certificate must be signed by the CA, host name is not checked
+	TLSVerifyFull = "verify-full" //tls, certificate must be signed by the CA and match the host name
+)
+
+//tlsMode returns the verify mode for c, verify-full when certificates are given without a mode
+func (c Config) tlsMode() string {
+	mode := strings.ToLower(c.TLSMode)
+	switch mode {
+	case "", "false":
+		if c.TLSCA != "" || c.TLSCert != "" {
+			return TLSVerifyFull
+		}
+		return TLSDisable
+	case "true":
+		return TLSVerifyFull
+	}
+	return mode
+}
+
+//TLSConfig build tls config from the CA bundle, client certificate and verify mode in c.
+//Returns nil when tls is disabled or preferred
+func TLSConfig(c Config) (*tls.Config, error) {
+	mode := c.tlsMode()
+	switch mode {
+	case TLSDisable, TLSPreferred:
+		return nil, nil
+	case TLSSkipVerify, TLSVerifyCA, TLSVerifyFull:
+	default:
+		return nil, errors.New("Invalid tls mode: " + c.TLSMode)
+	}
+	ret := &tls.Config{ServerName: c.Host, MinVersion: tls.VersionTLS12}
+	if c.TLSCA != "" {
+		pem, err := os.ReadFile(c.TLSCA)
+		if err != nil {
+			return nil, errors.New("Could not read tls ca: " + err.Error())
+		}
+		ret.RootCAs = x509.NewCertPool()
+		if !ret.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("No certificates found in " + c.TLSCA)
+		}
+	}
+	if c.TLSCert != "" || c.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+		if err != nil {
+			return nil, errors.New("Could not load tls client certificate: " + err.Error())
+		}
+		ret.Certificates = []tls.Certificate{cert}
+	}
+	switch mode {
+	case TLSSkipVerify:
+		ret.InsecureSkipVerify = true
+	case TLSVerifyCA:
+		//skip the default verification, which includes the host name, and check the chain only
+		ret.InsecureSkipVerify = true
+		ret.VerifyPeerCertificate = verifyChain(ret.RootCAs)
+	}
+	return ret, nil
+}
+
+//verifyChain returns function that verifies the server certificate against roots without checking the host name
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("No server certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+		opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(opts)
+		return err
+	}
+}
+
+//registerTLS register tls config for c with the mysql driver, returns the name to use in the DSN
+func registerTLS(c Config) (string, error) {
+	mode := c.tlsMode()
+	if mode == TLSDisable {
+		return "", nil
+	}
+	if mode == TLSPreferred {
+		return TLSPreferred, nil
+	}
+	tlsConfig, err := TLSConfig(c)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256([]byte(strings.Join([]string{c.Host, mode, c.TLSCA, c.TLSCert, c.TLSKey}, "\x00")))
+	name := "dbmodel-" + hex.EncodeToString(h[:8])
+	if err = mysql.RegisterTLSConfig(name, tlsConfig); err != nil {
+		return "", err
+	}
+	return name, nil
+}
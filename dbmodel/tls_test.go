@@ -0,0 +1,204 @@
+package dbmodel
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//testCerts CA, server and client certificates in a temporary directory
+type testCerts struct {
+	dir        string
+	ca         string //pem file of the CA
+	server     tls.Certificate
+	clientCert string
+	clientKey  string
+}
+
+//newCert returns certificate for name signed by parent, self-signed when parent is nil
+func newCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key, der
+}
+
+//writePEM write block of typ to a file in dir, returns the path
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestCerts(t *testing.T) testCerts {
+	t.Helper()
+	c := testCerts{dir: t.TempDir()}
+	ca, caKey, caDER := newCert(t, "Test CA", nil, nil)
+	c.ca = writePEM(t, c.dir, "ca.pem", "CERTIFICATE", caDER)
+	_, serverKey, serverDER := newCert(t, "db.example", ca, caKey)
+	c.server = tls.Certificate{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}
+	_, clientKey, clientDER := newCert(t, "app", ca, caKey)
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.clientCert = writePEM(t, c.dir, "client.pem", "CERTIFICATE", clientDER)
+	c.clientKey = writePEM(t, c.dir, "client-key.pem", "EC PRIVATE KEY", keyDER)
+	return c
+}
+
+//handshake returns error of the client side of a tls handshake with a server with cert
+func handshake(cert tls.Certificate, client *tls.Config) error {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	go tls.Server(serverConn, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+	return tls.Client(clientConn, client).Handshake()
+}
+
+func TestTLSMode(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{}, TLSDisable},
+		{Config{TLSMode: "false"}, TLSDisable},
+		{Config{TLSMode: "true"}, TLSVerifyFull},
+		{Config{TLSCA: "ca.pem"}, TLSVerifyFull},
+		{Config{TLSMode: "Verify-CA", TLSCA: "ca.pem"}, TLSVerifyCA},
+		{Config{TLSMode: "preferred"}, TLSPreferred},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.tlsMode(); got != tt.want {
+			t.Errorf("tlsMode(%+v) = %s, want %s", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestTLSConfigHandshake(t *testing.T) {
+	certs := newTestCerts(t)
+	tests := []struct {
+		mode, host string
+		ok         bool
+	}{
+		{TLSVerifyFull, "db.example", true},
+		{TLSVerifyFull, "10.0.0.1", false},
+		{TLSVerifyCA, "10.0.0.1", true},
+		{TLSSkipVerify, "10.0.0.1", true},
+	}
+	for _, tt := range tests {
+		cfg, err := TLSConfig(Config{Host: tt.host, TLSMode: tt.mode, TLSCA: certs.ca, TLSCert: certs.clientCert, TLSKey: certs.clientKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.Certificates) != 1 {
+			t.Error("Expected client certificate")
+		}
+		if err = handshake(certs.server, cfg); (err == nil) != tt.ok {
+			t.Errorf("%s %s: got %v", tt.mode, tt.host, err)
+		}
+	}
+
+	//a certificate of another CA is refused by verify-ca
+	other, otherKey, _ := newCert(t, "Other CA", nil, nil)
+	_, key, der := newCert(t, "db.example", other, otherKey)
+	cfg, err := TLSConfig(Config{Host: "db.example", TLSMode: TLSVerifyCA, TLSCA: certs.ca})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = handshake(tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cfg); err == nil {
+		t.Error("Expected certificate of another CA to be refused")
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	certs := newTestCerts(t)
+	roots := x509.NewCertPool()
+	pemBytes, _ := os.ReadFile(certs.ca)
+	roots.AppendCertsFromPEM(pemBytes)
+	verify := verifyChain(roots)
+	if err := verify(certs.server.Certificate, nil); err != nil {
+		t.Error("Expected valid chain, got", err)
+	}
+	if err := verify(nil, nil); err == nil {
+		t.Error("Expected error without certificates")
+	}
+	if err := verify([][]byte{[]byte("garbage")}, nil); err == nil {
+		t.Error("Expected error for invalid certificate")
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	certs := newTestCerts(t)
+	for _, cfg := range []Config{
+		{TLSMode: "sometimes"},
+		{TLSMode: TLSVerifyCA, TLSCA: filepath.Join(certs.dir, "missing.pem")},
+		{TLSMode: TLSVerifyCA, TLSCA: certs.clientKey},
+		{TLSMode: TLSVerifyFull, TLSCert: certs.clientCert},
+	} {
+		if _, err := TLSConfig(cfg); err == nil {
+			t.Errorf("Expected error for %+v", cfg)
+		}
+	}
+	if cfg, err := TLSConfig(Config{TLSMode: TLSPreferred}); cfg != nil || err != nil {
+		t.Error("Expected no config for preferred, got", cfg, err)
+	}
+}
+
+func TestRegisterTLS(t *testing.T) {
+	certs := newTestCerts(t)
+	cfg := Config{Host: "db.example", TLSCA: certs.ca}
+	name, err := registerTLS(cfg)
+	if err != nil || !strings.HasPrefix(name, "dbmodel-") {
+		t.Fatal("Expected registered name, got", name, err)
+	}
+	if again, _ := registerTLS(cfg); again != name {
+		t.Error("Expected the same name for the same config, got", again)
+	}
+	if other, _ := registerTLS(Config{Host: "other.example", TLSCA: certs.ca}); other == name {
+		t.Error("Expected another name for another host")
+	}
+	for mode, want := range map[string]string{TLSDisable: "", TLSPreferred: TLSPreferred} {
+		if got, err := registerTLS(Config{TLSMode: mode}); got != want || err != nil {
+			t.Errorf("registerTLS(%s) = %q %v, want %q", mode, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+//Tx transaction on the primary of a store. Statements run in the transaction until Commit or
+//Rollback
+type Tx struct {
+	ctx    context.Context
+	tx     *sql.Tx
+	parent *Store
+	store  *Store //runs statements in tx
+
+	mutex   sync.Mutex
+	written []tableName //invalidated in the query cache on commit
+}
+
+//Begin start transaction on the default store, the transaction is rolled back when ctx is done
+//before Commit
+func Begin(ctx context.Context) (*Tx, error) {
+	db, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return db.Begin(ctx)
+}
+
+//WithTx run fn in a transaction on the default store, see Store.WithTx
+func WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	db, err := Default()
+	if err != nil {
+		return err
+	}
+	return db.WithTx(ctx, fn)
+}
+
+//Begin start transaction on the primary, the transaction is rolled back when ctx is done before Commit
+func (s *Store) Begin(ctx context.Context) (*Tx, error) {
+	hctx, e := s.before(ctx, "begin", nil)
+	sqlTx, err := s.db.BeginTx(ctx, nil)
+	err = dbError(err)
+	s.after(hctx, e, nil, err)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tx{ctx: ctx, tx: sqlTx, parent: s}
+	//reads go to the primary and skip the query cache, a failed statement is not retried
+	store := *s
+	store.primary = true
+	store.cfg.Retries = 0
+	store.tx = t
+	t.store = &store
+	return t, nil
+}
+
+//WithTx run fn in a transaction. The transaction is committed when fn returns nil, rolled back
+//when fn returns an error or panics
+func (s *Store) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+//Store returns store that runs statements in the transaction, for Query, Fetch, the builder and
+//other functions that take a store
+func (t *Tx) Store() *Store {
+	return t.store
+}
+
+//Save database object in the transaction
+func (t *Tx) Save(obj DbObject) (int, error) {
+	dbName, tblName := obj.GetDbInfo()
+	n, _, err := save(t.ctx, t.store, dbName, tblName, obj.GetColumns())
+	return n, err
+}
+
+//Delete database object in the transaction
+func (t *Tx) Delete(obj DbObject) (int, error) {
+	dbName, tblName := obj.GetDbInfo()
+	return deleteRow(t.ctx, t.store, dbName, tblName, obj.GetColumns())
+}
+
+//Query run query in the transaction, see Query
+func (t *Tx) Query(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return QueryContext(t.ctx, t.store, query, args...)
+}
+
+//Exec run statement in the transaction
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.store.exec(t.ctx, query, args...)
+}
+
+//Commit commit the transaction, cached results of the tables written in it are dropped
+func (t *Tx) Commit() error {
+	ctx, e := t.parent.before(t.ctx, "commit", nil)
+	err := dbError(t.tx.Commit())
+	t.parent.after(ctx, e, nil, err)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, tbl := range t.written {
+		t.parent.invalidate(tbl.db, tbl.tbl)
+	}
+	t.written = nil
+	return err
+}
+
+//Rollback abort the transaction, returns sql.ErrTxDone when it was already committed or rolled back
+func (t *Tx) Rollback() error {
+	ctx, e := t.parent.before(t.ctx, "rollback", nil)
+	err := dbError(t.tx.Rollback())
+	t.parent.after(ctx, e, nil, err)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.written = nil
+	return err
+}
+
+//wrote remember table written in the transaction
+func (t *Tx) wrote(dbName, tblName string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.written = append(t.written, tableName{db: dbName, tbl: tblName})
+}
@@ -0,0 +1,165 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+//testObject DbObject with the columns of a row of main.tblName
+type testObject struct {
+	tblName string
+	cols    []Column
+}
+
+func (o testObject) GetDbInfo() (string, string)             { return "main", o.tblName }
+func (o testObject) GetColumns() []Column                    { return o.cols }
+func (o testObject) Get(key string) (Column, error)          { return Column{}, nil }
+func (o testObject) Set(key string, value interface{}) error { return nil }
+func (o testObject) Save() (int, error)                      { return 0, nil }
+func (o testObject) Delete() (int, error)                    { return 0, nil }
+
+//queryInt returns first value of query as int
+func queryInt(t *testing.T, db *Store, query string) int {
+	t.Helper()
+	rows, err := QueryTyped(db, query)
+	if err != nil || len(rows) == 0 {
+		t.Fatal(rows, err)
+	}
+	for _, v := range rows[0] {
+		n, err := ToInt(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	return 0
+}
+
+//openTx returns store with table t and function that returns an object for a row of t
+func openTx(t *testing.T) (*Store, func(id int, name string) DbObject) {
+	db := openSQLite(t, "create table t (id integer primary key, name text not null)")
+	cols := GetColumns(db, "main", "t")
+	return db, func(id int, name string) DbObject {
+		return testObject{tblName: "t", cols: withValues(cols, map[string]interface{}{"id": id, "name": name})}
+	}
+}
+
+func TestTxCommitRollback(t *testing.T) {
+	ctx := context.Background()
+	db, obj := openTx(t)
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Save(obj(1, "a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec("insert into t values (?, ?)", 2, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if rows, err := tx.Query("select * from t"); err != nil || len(rows) != 2 {
+		t.Error("Expected the rows of the transaction, got", rows, err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(); !errors.Is(err, sql.ErrTxDone) {
+		t.Error("Expected ErrTxDone for rollback after commit, got", err)
+	}
+	if n := queryInt(t, db, "select count(*) from t"); n != 2 {
+		t.Error("Expected 2 committed rows, got", n)
+	}
+
+	tx, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Delete(obj(1, "")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Save(obj(3, "c")); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); !errors.Is(err, sql.ErrTxDone) {
+		t.Error("Expected ErrTxDone for commit after rollback, got", err)
+	}
+	if n := queryInt(t, db, "select count(*) from t where id in (1, 2)"); n != 2 {
+		t.Error("Expected rolled back delete, got", n)
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	db, obj := openTx(t)
+	failed := errors.New("failed")
+	err := db.WithTx(context.Background(), func(tx *Tx) error {
+		tx.Save(obj(1, "a"))
+		return failed
+	})
+	if err != failed {
+		t.Error("Expected error of fn, got", err)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Error("Expected the panic to be passed on, got", p)
+			}
+		}()
+		db.WithTx(context.Background(), func(tx *Tx) error {
+			tx.Save(obj(2, "b"))
+			panic("boom")
+		})
+	}()
+	if n := queryInt(t, db, "select count(*) from t"); n != 0 {
+		t.Error("Expected rollback after error and panic, got", n, "rows")
+	}
+
+	err = db.WithTx(context.Background(), func(tx *Tx) error {
+		_, err := tx.Save(obj(3, "c"))
+		return err
+	})
+	if n := queryInt(t, db, "select count(*) from t"); err != nil || n != 1 {
+		t.Error("Expected commit, got", n, err)
+	}
+}
+
+func TestTxCache(t *testing.T) {
+	db, obj := openTx(t)
+	db.EnableCache(CacheOptions{TTL: time.Minute})
+	count := func(s *Store) int {
+		return queryInt(t, s, "select count(*) from t")
+	}
+	if count(db) != 0 || count(db) != 0 || db.CacheStats().Hits != 1 {
+		t.Fatal("Expected cached count, got", db.CacheStats())
+	}
+
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Save(obj(1, "a"))
+	if n := count(tx.Store()); n != 1 {
+		t.Error("Expected reads in the transaction to skip the cache, got", n)
+	}
+	tx.Rollback()
+	if n := count(db); n != 0 || db.CacheStats().Hits != 2 {
+		t.Error("Expected cached count after rollback, got", n, db.CacheStats())
+	}
+
+	if err = db.WithTx(context.Background(), func(tx *Tx) error {
+		_, err := tx.Save(obj(1, "a"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(db); n != 1 {
+		t.Error("Expected the cache of t to be invalidated on commit, got", n)
+	}
+}
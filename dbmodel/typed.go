@@ -0,0 +1,319 @@
+package dbmodel
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//timeFormats formats of date and time values as text, from mysql, sqlite and database/sql (for time.Time from postgres)
+var timeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+//QueryTyped Get slice of map[string]interface{} from database with values converted using the column
+//types: int64, uint64, float64, bool, time.Time, []byte, json.RawMessage, json.Number (decimals) or string.
+//NULL is nil. args are passed to the driver for the placeholders in query
+func QueryTyped(db *Store, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return queryTyped(context.Background(), db, query, args...)
+}
+
+//QueryTypedContext QueryTyped with context, the query is stopped when ctx is done
+func QueryTypedContext(ctx context.Context, db *Store, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return queryTyped(ctx, db, query, args...)
+}
+
+//queryTyped run query with arguments and return rows as maps with typed values
+func queryTyped(ctx context.Context, db *Store, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	res := make([]map[string]interface{}, 0)
+	err := queryEach(ctx, db, query, args, nil, func(row Row) error {
+		res = append(res, row.Map())
+		return nil
+	})
+	return res, err
+}
+
+//QueryEach run query and call fn for each row, rows are scanned one at a time so results of any
+//size can be processed. Stops and returns the error when fn returns an error
+func QueryEach(ctx context.Context, db *Store, query string, args []interface{}, fn func(Row) error) error {
+	return queryEach(ctx, db, query, args, nil, fn)
+}
+
+//queryEach run query and call fn for each row, header is called with the columns before the
+//rows, also when there are none. header may be nil
+func queryEach(ctx context.Context, db *Store, query string, args []interface{}, header func(columns, types []string) error, fn func(Row) error) error {
+	var converters []func([]byte) interface{}
+	var row Row
+	return scanRaw(ctx, db, query, args, func(columns, types []string) error {
+		converters = make([]func([]byte) interface{}, len(types))
+		for i, tp := range types {
+			converters[i] = converter(tp)
+		}
+		row = Row{columns: columns, types: types, values: make([]interface{}, len(columns))}
+		if header != nil {
+			return header(columns, types)
+		}
+		return nil
+	}, func(columns, types []string, values []sql.RawBytes) error {
+		for i, col := range values {
+			if col == nil {
+				row.values[i] = nil
+			} else {
+				row.values[i] = converters[i](col)
+			}
+		}
+		return fn(row)
+	})
+}
+
+//scanRaw run query and call fn with the column names, database type names and the values of each
+//row as text, NULL is nil. values are only valid inside fn. header is called with the columns
+//before the rows, also when there are none, it may be nil. Uses the query cache of the store
+func scanRaw(ctx context.Context, db *Store, query string, args []interface{}, header func(columns, types []string) error, fn func(columns, types []string, values []sql.RawBytes) error) error {
+	c, key, tables := db.cacheLookup(query, args)
+	var gen uint64
+	if c != nil {
+		if res, ok := c.get(key); ok {
+			if header != nil {
+				if err := header(res.columns, res.types); err != nil {
+					return err
+				}
+			}
+			for _, r := range res.rows {
+				if err := fn(res.columns, res.types, r); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		gen = c.generation()
+	}
+
+	rows, done, err := db.query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	types := make([]string, len(colTypes))
+	for i, ct := range colTypes {
+		types[i] = ct.DatabaseTypeName()
+	}
+	if header != nil {
+		if err = header(columns, types); err != nil {
+			return err
+		}
+	}
+	raw := make([]rawValue, len(columns))
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range raw {
+		scanArgs[i] = &raw[i]
+	}
+	var rec *cacheRecorder
+	if c != nil {
+		rec = c.record(gen, columns, types)
+	}
+	for rows.Next() {
+		if err = rows.Scan(scanArgs...); err != nil {
+			return err
+		}
+		for i := range raw {
+			values[i] = raw[i].bytes()
+		}
+		rec.add(values)
+		if err = fn(columns, types, values); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if rec != nil {
+		c.put(key, tables, rec)
+	}
+	return nil
+}
+
+//rawValue column value as text in a buffer that is reused for every row. Scanning an empty
+//value into a sql.RawBytes can leave it nil, which is NULL
+type rawValue struct {
+	b    []byte
+	null bool
+}
+
+//Scan convert driver value to text like database/sql does for sql.RawBytes
+func (v *rawValue) Scan(src interface{}) error {
+	v.null = src == nil
+	v.b = v.b[:0]
+	switch s := src.(type) {
+	case nil:
+	case []byte:
+		v.b = append(v.b, s...)
+	case string:
+		v.b = append(v.b, s...)
+	case int64:
+		v.b = strconv.AppendInt(v.b, s, 10)
+	case float64:
+		v.b = strconv.AppendFloat(v.b, s, 'g', -1, 64)
+	case bool:
+		v.b = strconv.AppendBool(v.b, s)
+	case time.Time:
+		v.b = s.AppendFormat(v.b, time.RFC3339Nano)
+	default:
+		v.b = append(v.b, fmt.Sprint(s)...)
+	}
+	return nil
+}
+
+//bytes returns value, nil for NULL
+func (v *rawValue) bytes() sql.RawBytes {
+	if v.null {
+		return nil
+	}
+	if v.b == nil {
+		v.b = make([]byte, 0, 16)
+	}
+	return v.b
+}
+
+//converter returns function that converts the text value of a column with database type name tp
+func converter(tp string) func([]byte) interface{} {
+	tp = strings.ToUpper(strings.TrimSpace(strings.Split(tp, "(")[0]))
+	switch {
+	case strings.HasPrefix(tp, "UNSIGNED "):
+		return func(b []byte) interface{} {
+			if v, err := strconv.ParseUint(string(b), 10, 64); err == nil {
+				return v
+			}
+			return string(b)
+		}
+	case strings.Contains(tp, "INT") || tp == "YEAR" || tp == "SERIAL" || tp == "BIGSERIAL":
+		return func(b []byte) interface{} {
+			if v, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+				return v
+			}
+			return string(b)
+		}
+	case tp == "FLOAT" || tp == "DOUBLE" || tp == "REAL" || tp == "FLOAT4" || tp == "FLOAT8" || tp == "DOUBLE PRECISION":
+		return func(b []byte) interface{} {
+			if v, err := strconv.ParseFloat(string(b), 64); err == nil {
+				return v
+			}
+			return string(b)
+		}
+	case tp == "DECIMAL" || tp == "NUMERIC":
+		//json.Number keeps the precision and is written as a number in json
+		return func(b []byte) interface{} {
+			return json.Number(string(b))
+		}
+	case tp == "BOOL" || tp == "BOOLEAN":
+		return func(b []byte) interface{} {
+			if v, err := strconv.ParseBool(string(b)); err == nil {
+				return v
+			}
+			return string(b)
+		}
+	case tp == "BIT":
+		//BIT(1) is used as bool
+		return func(b []byte) interface{} {
+			if len(b) == 1 {
+				return b[0] != 0
+			}
+			var v uint64
+			for _, c := range b {
+				v = v<<8 | uint64(c)
+			}
+			return v
+		}
+	case tp == "DATE" || tp == "DATETIME" || strings.HasPrefix(tp, "TIMESTAMP"):
+		return func(b []byte) interface{} {
+			for _, format := range timeFormats {
+				if v, err := time.Parse(format, string(b)); err == nil {
+					return v
+				}
+			}
+			//zero dates like 0000-00-00
+			return string(b)
+		}
+	case strings.Contains(tp, "BLOB") || strings.Contains(tp, "BINARY") || tp == "BYTEA":
+		return func(b []byte) interface{} {
+			return append([]byte{}, b...)
+		}
+	case tp == "JSON" || tp == "JSONB":
+		return func(b []byte) interface{} {
+			return json.RawMessage(append([]byte{}, b...))
+		}
+	}
+	return func(b []byte) interface{} {
+		return string(b)
+	}
+}
+
+//ToInt convert value from QueryTyped or Query to int, nil and empty string are 0
+func ToInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case uint64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case json.Number:
+		i, err := v.Int64()
+		return int(i), err
+	case string:
+		if v == "" || v == "NULL" {
+			return 0, nil
+		}
+		return strconv.Atoi(v)
+	case []byte:
+		return ToInt(string(v))
+	}
+	return 0, errors.New("Cannot convert " + fmt.Sprintf("%T", value) + " to int")
+}
+
+//ToString convert value from QueryTyped or Query to string, nil is empty string
+func ToString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case json.RawMessage:
+		return string(v)
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,103 @@
+package dbmodel
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConverter(t *testing.T) {
+	tests := []struct {
+		tp, text string
+		want     interface{}
+	}{
+		{"INT", "-42", int64(-42)},
+		{"bigint(20)", "7", int64(7)},
+		{"UNSIGNED BIGINT", "18446744073709551615", uint64(18446744073709551615)},
+		{"INT", "abc", "abc"},
+		{"DOUBLE", "2.5", 2.5},
+		{"float8", "x", "x"},
+		{"DECIMAL", "1.10", json.Number("1.10")},
+		{"BOOLEAN", "true", true},
+		{"BOOL", "1", true},
+		{"BIT", "\x01", true},
+		{"BIT", "\x01\x00", uint64(256)},
+		{"DATE", "2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"DATETIME", "2024-01-02 03:04:05", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"TIMESTAMP", "2024-01-02T03:04:05Z", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"DATETIME", "0000-00-00 00:00:00", "0000-00-00 00:00:00"},
+		{"BLOB", "\x00\x01", []byte{0, 1}},
+		{"JSON", `{"a":1}`, json.RawMessage(`{"a":1}`)},
+		{"VARCHAR", "12", "12"},
+		{"", "text", "text"},
+	}
+	for _, tt := range tests {
+		if got := converter(tt.tp)([]byte(tt.text)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("converter(%s)(%q) = %#v, want %#v", tt.tp, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestQueryTypedNull(t *testing.T) {
+	db := openSQLite(t, "create table v (i int, f real, d decimal(10,2), b boolean, t datetime, bl blob, s varchar(10))")
+	if _, err := db.DB().Exec("insert into v values (1, 1.5, 2.25, 1, '2024-01-02 03:04:05', x'0102', 'a'), (null, null, null, null, null, null, null)"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := QueryTyped(db, "select * from v order by i is null")
+	if err != nil || len(rows) != 2 {
+		t.Fatal(rows, err)
+	}
+	want := map[string]interface{}{
+		"i":  int64(1),
+		"f":  1.5,
+		"d":  json.Number("2.25"),
+		"b":  true,
+		"t":  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		"bl": []byte{1, 2},
+		"s":  "a",
+	}
+	if !reflect.DeepEqual(rows[0], want) {
+		t.Errorf("Got %#v, want %#v", rows[0], want)
+	}
+	for col, v := range rows[1] {
+		if v != nil {
+			t.Errorf("Expected NULL %s to be nil, got %#v", col, v)
+		}
+	}
+	if len(rows[1]) != len(want) {
+		t.Error("Expected all columns for NULLs, got", rows[1])
+	}
+}
+
+func TestToIntToString(t *testing.T) {
+	for _, v := range []interface{}{nil, "", "NULL"} {
+		if i, err := ToInt(v); i != 0 || err != nil {
+			t.Errorf("ToInt(%#v) = %d %v, want 0", v, i, err)
+		}
+	}
+	for _, v := range []interface{}{int64(3), uint64(3), 3.9, json.Number("3"), "3", []byte("3")} {
+		if i, err := ToInt(v); i != 3 || err != nil {
+			t.Errorf("ToInt(%#v) = %d %v, want 3", v, i, err)
+		}
+	}
+	if _, err := ToInt(time.Time{}); err == nil {
+		t.Error("Expected error for time")
+	}
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{nil, ""},
+		{int64(5), "5"},
+		{json.Number("1.10"), "1.10"},
+		{[]byte("b"), "b"},
+		{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "2024-01-02"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2024-01-02 03:04:05"},
+	}
+	for _, tt := range tests {
+		if got := ToString(tt.v); got != tt.want {
+			t.Errorf("ToString(%#v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
@@ -5,6 +5,8 @@ go 1.18
 require (
 	github.com/go-sql-driver/mysql v1.7.0
 	github.com/jmu0/settings v0.0.0-20201102123214-04fa97a1e9c1
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.17
 )
 
 require gopkg.in/yaml.v2 v2.4.0 // indirect
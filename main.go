@@ -7,11 +7,11 @@ import (
 )
 
 func main() {
-	db, err := dbmodel.Connect()
-	defer db.Close()
+	db, err := dbmodel.Default()
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer db.Close()
 	dbs := dbmodel.GetDatabaseNames(db)
 	fmt.Println("")
 	for _, name := range dbs {
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jmu0/orm/dbmodel"
+)
+
+//count returns number of rows of sel without limit and offset
+func (h *Handler) count(ctx context.Context, sel *dbmodel.SelectBuilder) (int, error) {
+	query, args := sel.ToSQL(h.db.Dialect())
+	total := 0
+	err := dbmodel.QueryEach(ctx, h.db, "select count(*) from ("+query+") t", args, func(row dbmodel.Row) error {
+		var err error
+		total, err = dbmodel.ToInt(row.Value(0))
+		return err
+	})
+	return total, err
+}
+
+//writePage write rows of the page as json array, or as envelope with the url of the next page.
+//Peek query returns the last row of the page and the row after it, it is read first for the Link
+//header. The rows of the page are written as they are read
+func (h *Handler) writePage(w http.ResponseWriter, r *http.Request, p *dbmodel.Page, total int, query string, args []interface{}, peek string, peekArgs []interface{}) {
+	var last dbmodel.Row
+	n := 0
+	err := dbmodel.QueryEach(r.Context(), h.db, peek, peekArgs, func(row dbmodel.Row) error {
+		if n == 0 {
+			last = row.Copy()
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		h.error(w, r, err, http.StatusInternalServerError, "Could not read rows")
+		return
+	}
+	var links []string
+	next := ""
+	params := r.URL.Query()
+	if n == 2 {
+		next = pageURL(r, p.Next(params, last))
+		links = append(links, "<"+next+">; rel=\"next\"")
+	}
+	if prev := p.Prev(params); prev != nil {
+		links = append(links, "<"+pageURL(r, prev)+">; rel=\"prev\"")
+	}
+
+	started := false
+	start := func() {
+		started = true
+		if len(links) > 0 {
+			w.Header().Set("Link", strings.Join(links, ", "))
+		}
+		if total > -1 {
+			w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if h.opts.Envelope {
+			w.Write([]byte("{\"data\":"))
+		}
+		w.Write([]byte("["))
+	}
+	err = dbmodel.QueryEach(r.Context(), h.db, query, args, func(row dbmodel.Row) error {
+		obj, err := h.rowJSON(row)
+		if err != nil {
+			return err
+		}
+		if started {
+			w.Write([]byte(","))
+		} else {
+			start()
+		}
+		_, err = w.Write(obj)
+		return err
+	})
+	if err != nil {
+		if !started {
+			h.error(w, r, err, http.StatusInternalServerError, "Could not read rows")
+			return
+		}
+		//the status is sent, the client gets invalid json
+		h.opts.Logger.Println("REST: ERROR: after writing rows:", r.Method, r.URL.Path, err)
+		return
+	}
+	if !started {
+		start()
+	}
+	w.Write([]byte("]"))
+	if h.opts.Envelope {
+		b := []byte(",\"next\":")
+		if next == "" {
+			b = append(b, "null"...)
+		} else {
+			value, _ := json.Marshal(next)
+			b = append(b, value...)
+		}
+		if total > -1 {
+			b = append(b, ",\"total\":"+strconv.Itoa(total)...)
+		}
+		w.Write(append(b, '}'))
+	}
+}
+
+//pageURL returns path of the request with query params, the path includes the prefix that was
+//stripped before the handler
+func pageURL(r *http.Request, params url.Values) string {
+	path := r.URL.EscapedPath()
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil && r.RequestURI != "" {
+		path = u.EscapedPath()
+	}
+	return path + "?" + params.Encode()
+}
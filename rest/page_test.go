@@ -0,0 +1,173 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+var linkReg = regexp.MustCompile(`<([^>]*)>; rel="(\w+)"`)
+
+//links returns urls of the Link header by rel
+func links(w http.ResponseWriter) map[string]string {
+	ret := make(map[string]string)
+	for _, m := range linkReg.FindAllStringSubmatch(w.Header().Get("Link"), -1) {
+		ret[m[2]] = m[1]
+	}
+	return ret
+}
+
+func openPages(t *testing.T) http.Handler {
+	db := openStore(t,
+		"create table p (id integer primary key, g int)",
+		"create table c (a int, b int, primary key (a, b))",
+		"create table n (v int)",
+	)
+	for i := 1; i <= 7; i++ {
+		db.DB().Exec("insert into p values (?, ?)", i, i%2)
+		db.DB().Exec("insert into c values (?, ?)", i/3, i)
+		db.DB().Exec("insert into n values (?)", i)
+	}
+	h := NewHandler(db, Options{PageSize: 3, MaxPageSize: 5, TotalCount: true})
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", h))
+	return mux
+}
+
+//walk follow the next links from target, returns the values of col by page
+func walk(t *testing.T, h http.Handler, target, col string) [][]float64 {
+	t.Helper()
+	var pages [][]float64
+	for target != "" && len(pages) < 10 {
+		w := do(h, "GET", target, nil)
+		if w.Code != 200 {
+			t.Fatal(target, w.Code, w.Body.String())
+		}
+		var page []float64
+		for _, row := range rows(t, w) {
+			page = append(page, row[col].(float64))
+		}
+		pages = append(pages, page)
+		target = links(w)["next"]
+	}
+	return pages
+}
+
+func TestPages(t *testing.T) {
+	h := openPages(t)
+	tests := []struct {
+		target, col string
+		pages       [][]float64
+	}{
+		//keyset on the primary key
+		{"/api/main/p", "id", [][]float64{{1, 2, 3}, {4, 5, 6}, {7}}},
+		{"/api/main/p?limit=100", "id", [][]float64{{1, 2, 3, 4, 5}, {6, 7}}},
+		{"/api/main/p?g=1&limit=2", "id", [][]float64{{1, 3}, {5, 7}}},
+		{"/api/main/c?limit=4", "b", [][]float64{{1, 2, 3, 4}, {5, 6, 7}}},
+		//offset for sorted rows and tables without key
+		{"/api/main/p?order=-id", "id", [][]float64{{7, 6, 5}, {4, 3, 2}, {1}}},
+		{"/api/main/n?limit=4", "v", [][]float64{{1, 2, 3, 4}, {5, 6, 7}}},
+	}
+	for _, tt := range tests {
+		if pages := walk(t, h, tt.target, tt.col); !reflect.DeepEqual(pages, tt.pages) {
+			t.Errorf("%s: got %v, want %v", tt.target, pages, tt.pages)
+		}
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	h := openPages(t)
+	w := do(h, "GET", "/api/main/p?g=1&order=-id&offset=1&limit=2", nil)
+	if w.Header().Get("X-Total-Count") != "4" {
+		t.Error("Expected total 4, got", w.Header().Get("X-Total-Count"))
+	}
+	want := map[string]string{
+		"next": "/api/main/p?g=1&limit=2&offset=3&order=-id",
+		"prev": "/api/main/p?g=1&limit=2&order=-id",
+	}
+	if got := links(w); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got links %v, want %v", got, want)
+	}
+	w = do(h, "GET", "/api/main/c?limit=2&after=1:3", nil)
+	if got := links(w)["next"]; got != "/api/main/c?after=1%3A5&limit=2" {
+		t.Error("Expected next after key 1:5, got", got)
+	}
+	if w = do(h, "GET", "/api/main/p?after=6", nil); w.Header().Get("Link") != "" {
+		t.Error("Expected no links on the last page, got", w.Header().Get("Link"))
+	}
+
+	for _, target := range []string{
+		"/api/main/p?limit=0",
+		"/api/main/p?offset=-1",
+		"/api/main/p?order=-id&after=2",
+		"/api/main/c?after=1",
+		"/api/main/n?after=1",
+	} {
+		if w := do(h, "GET", target, nil); w.Code != 400 {
+			t.Errorf("%s: expected 400, got %d", target, w.Code)
+		}
+	}
+}
+
+func TestPageEnvelope(t *testing.T) {
+	db := openStore(t, "create table p (id integer primary key)")
+	for i := 1; i <= 5; i++ {
+		db.DB().Exec("insert into p values (?)", i)
+	}
+	h := NewHandler(db, Options{Envelope: true, PageSize: 4, TotalCount: true})
+	var page struct {
+		Data  []map[string]int
+		Next  *string
+		Total int
+	}
+	for i, want := range []string{"/main/p?after=4&limit=4", ""} {
+		target := "/main/p"
+		if i > 0 {
+			target = "/main/p?after=4"
+		}
+		w := do(h, "GET", target, nil)
+		page.Next = nil
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(w.Body.String(), err)
+		}
+		next := ""
+		if page.Next != nil {
+			next = *page.Next
+		}
+		if next != want || page.Total != 5 || page.Data[0]["id"] != i*4+1 {
+			t.Errorf("%s: got %s", target, w.Body.String())
+		}
+	}
+}
+
+//writeRecorder records the headers of the first write and the number of writes
+type writeRecorder struct {
+	http.ResponseWriter
+	header http.Header
+	writes int
+}
+
+func (w *writeRecorder) Write(b []byte) (int, error) {
+	if w.writes == 0 {
+		w.header = w.Header().Clone()
+	}
+	w.writes++
+	return w.ResponseWriter.Write(b)
+}
+
+func TestPageStream(t *testing.T) {
+	h := openPages(t)
+	rec := httptest.NewRecorder()
+	w := &writeRecorder{ResponseWriter: rec}
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/main/p?limit=5", nil))
+	if w.header.Get("Link") != `</api/main/p?after=5&limit=5>; rel="next"` || w.header.Get("X-Total-Count") != "7" {
+		t.Error("Expected headers before the rows, got", w.header)
+	}
+	//the rows are written one by one
+	if w.writes < 5 || rec.Body.String() != `[{"id":1,"g":1},{"id":2,"g":0},{"id":3,"g":1},{"id":4,"g":0},{"id":5,"g":1}]` {
+		t.Error("Expected rows written as they are read, got", w.writes, rec.Body.String())
+	}
+}
@@ -0,0 +1,323 @@
+//Package rest REST api for the tables of a dbmodel store
+package rest
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jmu0/orm/dbmodel"
+)
+
+//Middleware wraps the handler of the REST api, e.g. for authentication or logging. Use RouteOf
+//for the database, table and key of the request
+type Middleware func(http.Handler) http.Handler
+
+//Options configure a Handler
+type Options struct {
+	ReadOnly      bool                                                           //only GET requests
+	Databases     []string                                                       //databases that can be used, all when empty
+	RawWhere      bool                                                           //allow where clauses in the q parameter of a table GET, trusted clients only: full sql read access
+	HiddenColumns *regexp.Regexp                                                 //columns left out of results and filters, dbmodel.PasswordColumns when nil
+	Middleware    []Middleware                                                   //the first is the outermost
+	OnWrite       func(r *http.Request, route Route, obj map[string]interface{}) //called after a row is saved or deleted
+	Logger        *log.Logger                                                    //log.Default() when nil
+	PageSize      int                                                            //rows of a table GET without limit, dbmodel.DefaultPageSize when 0
+	MaxPageSize   int                                                            //max limit of a table GET, dbmodel.DefaultMaxPageSize when 0
+	TotalCount    bool                                                           //X-Total-Count header with the number of rows of a table GET
+	Envelope      bool                                                           //write table GETs as {"data": rows, "next": url of the next page}
+}
+
+//Handler http.Handler for the REST api of a store. Mount it under a prefix with http.StripPrefix:
+//
+//	http.Handle("/api/", http.StripPrefix("/api", rest.NewHandler(db, rest.Options{})))
+//
+//Paths are db (GET: table names), db/table (GET: rows, POST: save) and db/table/key (GET: row,
+//POST: save, DELETE: delete)
+type Handler struct {
+	db      *dbmodel.Store
+	opts    Options
+	query   dbmodel.TableQuery
+	handler http.Handler
+}
+
+//NewHandler returns REST handler for db
+func NewHandler(db *dbmodel.Store, opts Options) *Handler {
+	if opts.HiddenColumns == nil {
+		opts.HiddenColumns = dbmodel.PasswordColumns
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	h := &Handler{db: db, opts: opts}
+	h.query = dbmodel.TableQuery{
+		RawWhere:      opts.RawWhere,
+		HiddenColumns: opts.HiddenColumns,
+		PageSize:      opts.PageSize,
+		MaxPageSize:   opts.MaxPageSize,
+	}
+	h.handler = http.HandlerFunc(h.serve)
+	for i := len(opts.Middleware) - 1; i >= 0; i-- {
+		h.handler = opts.Middleware[i](h.handler)
+	}
+	return h
+}
+
+//ServeHTTP parse the route of the request and run it through the middleware
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if route, err := ParseRoute(r.URL.Path); err == nil {
+		r = withRoute(r, route)
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	route, ok := RouteOf(r)
+	if !ok || !h.allowed(route.Database) {
+		http.NotFound(w, r)
+		return
+	}
+	if h.opts.ReadOnly && r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	if route.Table == "" {
+		h.tables(w, r, route)
+		return
+	}
+	cols := dbmodel.GetColumnsContext(r.Context(), h.db, route.Database, route.Table)
+	if len(cols) == 0 {
+		http.Error(w, "Table doesn't exist", http.StatusNotFound)
+		return
+	}
+	switch {
+	case route.Key == nil && r.Method == http.MethodGet:
+		h.list(w, r, route, cols)
+	case route.Key != nil && r.Method == http.MethodGet:
+		h.get(w, r, route, cols)
+	case r.Method == http.MethodPost:
+		h.save(w, r, route, cols)
+	case route.Key != nil && r.Method == http.MethodDelete:
+		h.delete(w, r, route, cols)
+	case route.Key == nil:
+		methodNotAllowed(w, "GET, POST")
+	default:
+		methodNotAllowed(w, "GET, POST, DELETE")
+	}
+}
+
+//allowed true when database can be used
+func (h *Handler) allowed(dbName string) bool {
+	if len(h.opts.Databases) == 0 {
+		return true
+	}
+	for _, name := range h.opts.Databases {
+		if name == dbName {
+			return true
+		}
+	}
+	return false
+}
+
+//tables write table names of the database
+func (h *Handler) tables(w http.ResponseWriter, r *http.Request, route Route) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	tbls := dbmodel.GetTableNamesContext(r.Context(), h.db, route.Database)
+	if len(tbls) == 0 {
+		http.Error(w, "Database doesn't exist", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, tbls)
+}
+
+//list write page of rows of the table as json array, filtered and sorted with the url query
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, route Route, cols []dbmodel.Column) {
+	sel, p, err := h.query.Select(route.Database, route.Table, h.db.Dialect(), cols, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	total := -1
+	if h.opts.TotalCount {
+		if total, err = h.count(r.Context(), sel); err != nil {
+			h.error(w, r, err, http.StatusInternalServerError, "Could not count rows")
+			return
+		}
+	}
+	p.Apply(sel)
+	query, args := sel.ToSQL(h.db.Dialect())
+	//the last row of the page and the row after it, to know the next page before the rows are written
+	sel.Limit(2).Offset(p.Offset + p.Limit - 1)
+	peek, peekArgs := sel.ToSQL(h.db.Dialect())
+	h.writePage(w, r, p, total, query, args, peek, peekArgs)
+}
+
+//get write row with the key of the route as json object
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, route Route, cols []dbmodel.Column) {
+	if !setKey(cols, route.Key) {
+		http.Error(w, "Invalid key", http.StatusBadRequest)
+		return
+	}
+	eq := dbmodel.Eq{}
+	for _, c := range cols {
+		if c.Key == "PRI" {
+			eq[c.Field] = c.Value
+		}
+	}
+	query, args := dbmodel.Select().From(route.Database, route.Table).Where(eq).ToSQL(h.db.Dialect())
+	var obj []byte
+	err := dbmodel.QueryEach(dbmodel.Prepared(r.Context()), h.db, query, args, func(row dbmodel.Row) error {
+		var err error
+		if obj == nil {
+			obj, err = h.rowJSON(row)
+		}
+		return err
+	})
+	if err != nil {
+		h.error(w, r, err, http.StatusInternalServerError, "Could not read row")
+		return
+	}
+	if obj == nil {
+		http.Error(w, "No results found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(obj)
+}
+
+//save insert or update row with the values of the request, the key of the route is used for
+//the primary key
+func (h *Handler) save(w http.ResponseWriter, r *http.Request, route Route, cols []dbmodel.Column) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	for i, c := range cols {
+		if values, ok := r.Form[c.Field]; ok {
+			cols[i].Value = strings.Join(values, "")
+		}
+	}
+	if route.Key != nil && !setKey(cols, route.Key) {
+		http.Error(w, "Invalid key", http.StatusBadRequest)
+		return
+	}
+	n, id, err := dbmodel.SaveColumns(r.Context(), h.db, route.Database, route.Table, cols)
+	if err != nil {
+		h.error(w, r, err, http.StatusInternalServerError, "Could not save")
+		return
+	}
+	if n == 1 && id > -1 {
+		//auto increment key that was not in the request
+		for i, c := range cols {
+			if c.Key == "PRI" && dbmodel.GetType(c.Type) == "int" && c.Value == nil {
+				cols[i].Value = id
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write([]byte("{\"n\":\"" + strconv.Itoa(n) + "\",\"id\":\"" + strconv.Itoa(id) + "\"}"))
+	h.wrote(r, route, cols)
+}
+
+//delete delete row with the key of the route
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, route Route, cols []dbmodel.Column) {
+	if !setKey(cols, route.Key) {
+		http.Error(w, "Invalid key", http.StatusBadRequest)
+		return
+	}
+	n, err := dbmodel.DeleteColumns(r.Context(), h.db, route.Database, route.Table, cols)
+	if err != nil {
+		h.error(w, r, err, http.StatusInternalServerError, "Could not delete")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write([]byte("{\"n\":\"" + strconv.Itoa(n) + "\"}"))
+	h.wrote(r, route, cols)
+}
+
+//wrote call OnWrite with the columns that have a value
+func (h *Handler) wrote(r *http.Request, route Route, cols []dbmodel.Column) {
+	if h.opts.OnWrite == nil {
+		return
+	}
+	obj := make(map[string]interface{})
+	for _, c := range cols {
+		if c.Value != nil {
+			obj[c.Field] = c.Value
+		}
+	}
+	h.opts.OnWrite(r, route, obj)
+}
+
+//rowJSON returns row as json object with the keys of row.Keys in select order, without hidden
+//columns
+func (h *Handler) rowJSON(row dbmodel.Row) ([]byte, error) {
+	obj := []byte("{")
+	keys := row.Keys()
+	for i, c := range row.Columns() {
+		if h.opts.HiddenColumns.MatchString(c) {
+			continue
+		}
+		value, err := json.Marshal(row.Value(i))
+		if err != nil {
+			return nil, err
+		}
+		key, _ := json.Marshal(keys[i])
+		if len(obj) > 1 {
+			obj = append(obj, ',')
+		}
+		obj = append(obj, key...)
+		obj = append(obj, ':')
+		obj = append(obj, value...)
+	}
+	return append(obj, '}'), nil
+}
+
+//error write error response, see dbmodel.ErrorStatus. Server errors are logged
+func (h *Handler) error(w http.ResponseWriter, r *http.Request, err error, status int, msg string) {
+	status, text := dbmodel.ErrorStatus(err, status, msg)
+	if status >= http.StatusInternalServerError {
+		h.opts.Logger.Println("REST: ERROR:", r.Method, r.URL.Path, err)
+	}
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	http.Error(w, text, status)
+}
+
+//setKey put key values in the primary key columns, false when the number of values is not the
+//number of key columns
+func setKey(cols []dbmodel.Column, key []string) bool {
+	i := 0
+	for index, c := range cols {
+		if c.Key == "PRI" {
+			if i == len(key) {
+				return false
+			}
+			cols[index].Value = key[i]
+			i++
+		}
+	}
+	return i > 0 && i == len(key)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Could not encode json", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(bytes)
+}
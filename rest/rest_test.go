@@ -0,0 +1,193 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jmu0/orm/dbmodel"
+)
+
+//openStore returns in-memory sqlite store with the tables of schema
+func openStore(t *testing.T, schema ...string) *dbmodel.Store {
+	t.Helper()
+	db, err := dbmodel.Open(dbmodel.Config{Dialect: "sqlite"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range schema {
+		if _, err := db.DB().Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+//do send request to h, body is form data
+func do(h http.Handler, method, target string, form url.Values) *httptest.ResponseRecorder {
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+//rows returns json array of the response
+func rows(t *testing.T, w *httptest.ResponseRecorder) []map[string]interface{} {
+	t.Helper()
+	var ret []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &ret); err != nil {
+		t.Fatal(w.Code, w.Body.String(), err)
+	}
+	return ret
+}
+
+const usersSchema = "create table users (id integer primary key, name text unique, password text)"
+
+func TestHandlerRoutes(t *testing.T) {
+	db := openStore(t, usersSchema,
+		"create table lines (a integer, b integer, v text, primary key (a, b))",
+		"insert into users values (1, 'x', 'secret'), (2, 'y', 'p')",
+	)
+	h := http.StripPrefix("/api", NewHandler(db, Options{}))
+	tests := []struct {
+		method, target string
+		form           url.Values
+		status         int
+		body           string
+	}{
+		{"GET", "/api/main", nil, 200, `["lines","users"]`},
+		{"GET", "/api/nope", nil, 404, ""},
+		{"GET", "/api/main/nope", nil, 404, "Table doesn't exist"},
+		{"GET", "/api/main/users/1", nil, 200, `{"id":1,"name":"x"}`},
+		{"GET", "/api/main/users/9", nil, 404, "No results found"},
+		{"GET", "/api/main/users/1:2", nil, 400, "Invalid key"},
+		{"POST", "/api/main/users", url.Values{"name": {"z"}}, 200, `{"n":"1","id":"3"}`},
+		{"POST", "/api/main/users/1", url.Values{"name": {"y"}}, 409, "Duplicate key: users.name"},
+		{"POST", "/api/main/lines/1:2", url.Values{"v": {"hi"}}, 200, ""},
+		{"GET", "/api/main/lines/1:2", nil, 200, `{"a":1,"b":2,"v":"hi"}`},
+		{"DELETE", "/api/main/lines/1:2", nil, 200, `{"n":"0"}`},
+		{"DELETE", "/api/main/lines/1:2", nil, 404, "No rows found"},
+		{"DELETE", "/api/main/users", nil, 405, "Method not allowed"},
+		{"PUT", "/api/main/users/1", nil, 405, "Method not allowed"},
+		{"POST", "/api/main", nil, 405, "Method not allowed"},
+	}
+	for _, tt := range tests {
+		w := do(h, tt.method, tt.target, tt.form)
+		if w.Code != tt.status || (tt.body != "" && strings.TrimSpace(w.Body.String()) != tt.body) {
+			t.Errorf("%s %s: got %d %s, want %d %s", tt.method, tt.target, w.Code, strings.TrimSpace(w.Body.String()), tt.status, tt.body)
+		}
+	}
+}
+
+func TestHandlerFilters(t *testing.T) {
+	db := openStore(t, usersSchema, "insert into users values (1, 'a', 'p1'), (2, 'b', 'p2'), (3, 'c', 'p3')")
+	h := NewHandler(db, Options{RawWhere: true})
+	tests := []struct {
+		target string
+		status int
+		ids    []float64
+	}{
+		{"/main/users", 200, []float64{1, 2, 3}},
+		{"/main/users?name=b", 200, []float64{2}},
+		{"/main/users?name=a&name=c", 200, []float64{1, 3}},
+		{"/main/users?order=-name", 200, []float64{3, 2, 1}},
+		{"/main/users?q=id>1", 200, []float64{2, 3}},
+		{"/main/users?order=nope", 400, nil},
+		{"/main/users?password=p1", 400, nil},
+		{"/main/users?order=-Password", 400, nil},
+		{"/main/users?q=password='p1'", 400, nil},
+	}
+	for _, tt := range tests {
+		w := do(h, "GET", tt.target, nil)
+		if w.Code != tt.status {
+			t.Errorf("%s: got %d %s, want %d", tt.target, w.Code, w.Body.String(), tt.status)
+			continue
+		}
+		if tt.status != 200 {
+			continue
+		}
+		var ids []float64
+		for _, row := range rows(t, w) {
+			if _, ok := row["password"]; ok {
+				t.Errorf("%s: password in result", tt.target)
+			}
+			ids = append(ids, row["id"].(float64))
+		}
+		if !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("%s: got ids %v, want %v", tt.target, ids, tt.ids)
+		}
+	}
+	if w := do(NewHandler(db, Options{}), "GET", "/main/users?q=id=1", nil); w.Code != 400 {
+		t.Error("Expected q to be refused without RawWhere, got", w.Code)
+	}
+}
+
+func TestHandlerOptions(t *testing.T) {
+	db := openStore(t, usersSchema, "create table temp.x (id integer primary key)")
+	var seen []string
+	var wrote []Route
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, _ := RouteOf(r)
+			seen = append(seen, r.Method+" "+route.String())
+			next.ServeHTTP(w, r)
+		})
+	}
+	onWrite := func(r *http.Request, route Route, obj map[string]interface{}) {
+		wrote = append(wrote, route)
+	}
+	h := NewHandler(db, Options{Databases: []string{"main"}, Middleware: []Middleware{mw}, OnWrite: onWrite})
+	if w := do(h, "GET", "/temp/x", nil); w.Code != 404 {
+		t.Error("Expected 404 for database that is not allowed, got", w.Code)
+	}
+	do(h, "POST", "/main/users/4", url.Values{"name": {"d"}})
+	if !reflect.DeepEqual(seen, []string{"GET temp/x", "POST main/users/4"}) {
+		t.Error("Middleware saw", seen)
+	}
+	if len(wrote) != 1 || wrote[0].String() != "main/users/4" {
+		t.Error("OnWrite got", wrote)
+	}
+
+	ro := NewHandler(db, Options{ReadOnly: true})
+	if w := do(ro, "POST", "/main/users", url.Values{"name": {"e"}}); w.Code != 405 || w.Header().Get("Allow") != "GET" {
+		t.Error("Expected 405 for read only handler, got", w.Code)
+	}
+}
+
+func TestParseRoute(t *testing.T) {
+	tests := []struct {
+		path  string
+		route Route
+		err   bool
+	}{
+		{"shop", Route{Database: "shop"}, false},
+		{"/shop/orders/", Route{Database: "shop", Table: "orders"}, false},
+		{"shop/lines/12:3", Route{Database: "shop", Table: "lines", Key: []string{"12", "3"}}, false},
+		{`shop/files/"docs/a.txt"`, Route{Database: "shop", Table: "files", Key: []string{"docs/a.txt"}}, false},
+		{"", Route{}, true},
+		{"shop//x", Route{}, true},
+	}
+	for _, tt := range tests {
+		route, err := ParseRoute(tt.path)
+		if (err != nil) != tt.err || !reflect.DeepEqual(route, tt.route) {
+			t.Errorf("ParseRoute(%q) = %#v %v", tt.path, route, err)
+			continue
+		}
+		if !tt.err {
+			if again, _ := ParseRoute(route.String()); !reflect.DeepEqual(again, route) {
+				t.Errorf("ParseRoute(%q.String()) = %#v", tt.path, again)
+			}
+		}
+	}
+}
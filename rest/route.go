@@ -0,0 +1,72 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+//Route database, table and primary key of a REST url
+type Route struct {
+	Database string
+	Table    string   //empty for the list of tables
+	Key      []string //primary key values in the order of the key columns, nil for the rows of a table
+}
+
+//routeKey context key of the route of a request
+type routeKey struct{}
+
+//ParseRoute returns route of path relative to where the handler is mounted: db, db/table or
+//db/table/key. Values of a key with more columns are separated by ":", e.g. shop/lines/12:3.
+//Keys that contain "/" are quoted: shop/files/"docs/a.txt"
+func ParseRoute(path string) (Route, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return Route{}, errors.New("No database in path")
+	}
+	parts := strings.Split(path, "/")
+	route := Route{Database: parts[0]}
+	if len(parts) > 1 {
+		route.Table = parts[1]
+	}
+	if len(parts) > 2 {
+		key := strings.Join(parts[2:], "/")
+		if len(key) > 1 && key[0] == '"' && key[len(key)-1] == '"' {
+			key = key[1 : len(key)-1]
+		}
+		route.Key = strings.Split(key, ":")
+	}
+	if route.Database == "" || (len(parts) > 1 && route.Table == "") {
+		return Route{}, errors.New("Invalid path: " + path)
+	}
+	return route, nil
+}
+
+//String returns route as path, the inverse of ParseRoute
+func (r Route) String() string {
+	path := r.Database
+	if r.Table != "" {
+		path += "/" + r.Table
+	}
+	if r.Key != nil {
+		key := strings.Join(r.Key, ":")
+		if strings.Contains(key, "/") {
+			key = "\"" + key + "\""
+		}
+		path += "/" + key
+	}
+	return path
+}
+
+//RouteOf returns route of a request to a Handler, for middleware. false when the path is not a
+//valid route
+func RouteOf(r *http.Request) (Route, bool) {
+	route, ok := r.Context().Value(routeKey{}).(Route)
+	return route, ok
+}
+
+//withRoute returns r with route in the context
+func withRoute(r *http.Request, route Route) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, route))
+}